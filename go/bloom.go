@@ -0,0 +1,90 @@
+package main
+
+import "sync/atomic"
+
+// bloomBitsPerEntry and bloomHashes give a false positive rate of about 1%
+const (
+	bloomBitsPerEntry = 10
+	bloomHashes       = 7
+)
+
+// FNV-1a parameters used to hash filter entries
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+)
+
+// minBloomCapacity is the smallest number of entries a bloom filter is sized for
+const minBloomCapacity = 1024
+
+// bloomFilter is a set of strings that can report false positives but never false
+// negatives. The prefix map consults it, with UseBloomFilter, to reject prefixes
+// that are definitely not stored without hashing into the map.
+type bloomFilter struct {
+	bits     []uint64
+	count    int
+	capacity int
+	
+	// rejected counts the lookups answered by the filter alone
+	rejected atomic.Uint64
+}
+
+// newBloomFilter creates an empty filter sized for capacity entries
+func newBloomFilter(capacity int) *bloomFilter {
+	capacity = max(capacity, minBloomCapacity)
+	return &bloomFilter{
+		bits:     make([]uint64, (capacity*bloomBitsPerEntry+63)/64),
+		capacity: capacity,
+	}
+}
+
+// positions returns the two hashes of s that every probe position is derived from
+func (f *bloomFilter) positions(s string) (uint64, uint64) {
+	// 64-bit FNV-1a, inlined so probing does not allocate
+	sum := uint64(fnvOffset64)
+	for i := 0; i < len(s); i++ {
+		sum ^= uint64(s[i])
+		sum *= fnvPrime64
+	}
+	return sum, sum>>32 | 1
+}
+
+// add inserts s into the filter
+func (f *bloomFilter) add(s string) {
+	h1, h2 := f.positions(s)
+	n := uint64(len(f.bits) * 64)
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % n
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.count++
+}
+
+// mayContain reports whether s may have been added; false means it definitely was not
+func (f *bloomFilter) mayContain(s string) bool {
+	h1, h2 := f.positions(s)
+	n := uint64(len(f.bits) * 64)
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % n
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			f.rejected.Add(1)
+			return false
+		}
+	}
+	return true
+}
+
+// full reports whether the filter holds as many entries as it was sized for, past
+// which its false positive rate climbs
+func (f *bloomFilter) full() bool {
+	return f.count >= f.capacity
+}
+
+// clone returns an independent copy of the filter
+func (f *bloomFilter) clone() *bloomFilter {
+	return &bloomFilter{
+		bits:     append([]uint64(nil), f.bits...),
+		count:    f.count,
+		capacity: f.capacity,
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// diskIndexMagic identifies files written by WriteDiskIndex
+const diskIndexMagic = "SSTIDX1\n"
+
+// defaultDiskCacheBuckets is the number of prefix buckets a DiskSearchTree keeps in memory
+const defaultDiskCacheBuckets = 256
+
+// ErrInvalidDiskIndex is returned when a file is not a valid disk index
+var ErrInvalidDiskIndex = errors.New("invalid disk index")
+
+// DiskSearchTree answers prefix queries from an index file written by WriteDiskIndex.
+// Only the prefix directory is held in memory; match lists are read from disk on
+// demand and the most recently used ones are kept in a small LRU cache.
+type DiskSearchTree struct {
+	mu       sync.Mutex
+	file     *os.File
+	dir      map[string]bucketRef
+	opts     Options
+	pipeline pipeline
+	cache    *lruCache
+}
+
+var _ PrefixSearcher = (*DiskSearchTree)(nil)
+
+// bucketRef locates the encoded match list of one prefix within the file
+type bucketRef struct {
+	offset int64
+	length int64
+}
+
+// WriteDiskIndex writes the tree to path in a format that OpenDiskIndex can search
+// without loading it fully into memory.
+//
+// Layout: magic, options (length-prefixed JSON), the match list of every prefix,
+// the prefix directory, and finally the 8-byte offset of the directory.
+func (sst *StaticSearchTree) WriteDiskIndex(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := sst.writeDiskIndex(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeDiskIndex encodes the tree to w in the disk index format
+func (sst *StaticSearchTree) writeDiskIndex(w io.Writer) error {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	
+	opts, err := json.Marshal(sst.opts)
+	if err != nil {
+		return err
+	}
+	cw.writeString(diskIndexMagic)
+	cw.writeUvarint(uint64(len(opts)))
+	cw.write(opts)
+	
+	prefixes := sst.prefixes()
+	refs := make([]bucketRef, len(prefixes))
+	for i, prefix := range prefixes {
+		start := cw.n
+		matches, _ := sst.lookup(prefix)
+		cw.writeUvarint(uint64(len(matches)))
+		for _, word := range matches {
+			cw.writeUvarint(uint64(len(word)))
+			cw.writeString(word)
+		}
+		refs[i] = bucketRef{offset: start, length: cw.n - start}
+	}
+	
+	dirOffset := cw.n
+	cw.writeUvarint(uint64(len(prefixes)))
+	for i, prefix := range prefixes {
+		cw.writeUvarint(uint64(len(prefix)))
+		cw.writeString(prefix)
+		cw.writeUvarint(uint64(refs[i].offset))
+		cw.writeUvarint(uint64(refs[i].length))
+	}
+	var trailer [8]byte
+	binary.LittleEndian.PutUint64(trailer[:], uint64(dirOffset))
+	cw.write(trailer[:])
+	
+	if cw.err != nil {
+		return cw.err
+	}
+	return cw.w.Flush()
+}
+
+// OpenDiskIndex opens an index file written by WriteDiskIndex
+func OpenDiskIndex(path string) (*DiskSearchTree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := openDiskIndex(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("open disk index %s: %w", path, err)
+	}
+	return dst, nil
+}
+
+// openDiskIndex reads the header and prefix directory of f
+func openDiskIndex(f *os.File) (*DiskSearchTree, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < int64(len(diskIndexMagic))+8 {
+		return nil, ErrInvalidDiskIndex
+	}
+	
+	header := newDiskReader(f, 0, info.Size())
+	magic := make([]byte, len(diskIndexMagic))
+	if _, err := io.ReadFull(header.r, magic); err != nil || string(magic) != diskIndexMagic {
+		return nil, ErrInvalidDiskIndex
+	}
+	header.remaining -= int64(len(magic))
+	rawOpts, err := header.readString()
+	if err != nil {
+		return nil, ErrInvalidDiskIndex
+	}
+	var opts Options
+	if err := json.Unmarshal([]byte(rawOpts), &opts); err != nil {
+		return nil, err
+	}
+	
+	var trailer [8]byte
+	if _, err := f.ReadAt(trailer[:], info.Size()-8); err != nil {
+		return nil, err
+	}
+	dirOffset := int64(binary.LittleEndian.Uint64(trailer[:]))
+	if dirOffset < 0 || dirOffset > info.Size()-8 {
+		return nil, ErrInvalidDiskIndex
+	}
+	
+	r := newDiskReader(f, dirOffset, info.Size()-8-dirOffset)
+	count, err := r.readLength()
+	if err != nil {
+		return nil, ErrInvalidDiskIndex
+	}
+	dir := make(map[string]bucketRef, count)
+	for i := 0; i < count; i++ {
+		prefix, err := r.readString()
+		if err != nil {
+			return nil, ErrInvalidDiskIndex
+		}
+		offset, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, ErrInvalidDiskIndex
+		}
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, ErrInvalidDiskIndex
+		}
+		
+		// Buckets lie between the options and the directory
+		if offset > uint64(dirOffset) || length > uint64(dirOffset)-offset {
+			return nil, ErrInvalidDiskIndex
+		}
+		dir[prefix] = bucketRef{offset: int64(offset), length: int64(length)}
+	}
+	
+	return &DiskSearchTree{
+		file:     f,
+		dir:      dir,
+		opts:     opts,
+		pipeline: buildPipeline(opts),
+		cache:    newLRUCache(defaultDiskCacheBuckets),
+	}, nil
+}
+
+// Search performs a prefix search, reading the prefix's bucket from disk if it
+// is not cached. Read errors are treated as no matches.
+func (dst *DiskSearchTree) Search(query string) []string {
+	matches, err := dst.SearchErr(query)
+	if err != nil {
+		return []string{}
+	}
+	return matches
+}
+
+// SearchErr performs a prefix search like Search but reports read errors
+func (dst *DiskSearchTree) SearchErr(query string) ([]string, error) {
+	prefix := dst.pipeline.normalize(query)
+	if dst.opts.shortQuery(prefix) {
+		return []string{}, nil
+	}
+	
+	dst.mu.Lock()
+	defer dst.mu.Unlock()
+	
+	// Queries longer than MaxPrefixLength filter the bucket of their stored prefix
+	stored := indexedPrefix(prefix, dst.opts.MaxPrefixLength)
+	matches, ok := dst.cache.get(stored)
+	if !ok {
+		ref, exists := dst.dir[stored]
+		if !exists {
+			return []string{}, nil
+		}
+		var err error
+		matches, err = dst.readBucket(ref)
+		if err != nil {
+			return nil, err
+		}
+		dst.cache.put(stored, matches)
+	}
+	if stored != prefix {
+		matches = filterByPrefix(matches, prefix, dst.pipeline, dst.opts)
+	}
+	
+	result := make([]string, len(matches))
+	copy(result, matches)
+	if dst.opts.WhitespaceInsensitiveDedup {
+		result = collapseSeparatorVariants(result)
+	}
+	return result, nil
+}
+
+// readBucket decodes the match list stored at ref
+func (dst *DiskSearchTree) readBucket(ref bucketRef) ([]string, error) {
+	r := newDiskReader(dst.file, ref.offset, ref.length)
+	count, err := r.readLength()
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		word, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, word)
+	}
+	return matches, nil
+}
+
+// Size returns the number of stored prefixes
+func (dst *DiskSearchTree) Size() int {
+	return len(dst.dir)
+}
+
+// Close closes the underlying index file
+func (dst *DiskSearchTree) Close() error {
+	return dst.file.Close()
+}
+
+// diskReader reads uvarints and strings from a section of an index file, tracking
+// how many bytes of the section remain so that corrupt lengths and counts are
+// rejected before anything is allocated for them
+type diskReader struct {
+	r         *bufio.Reader
+	remaining int64
+}
+
+// newDiskReader reads the length bytes of f starting at offset
+func newDiskReader(f io.ReaderAt, offset, length int64) *diskReader {
+	return &diskReader{r: bufio.NewReader(io.NewSectionReader(f, offset, length)), remaining: length}
+}
+
+// ReadByte implements io.ByteReader for binary.ReadUvarint
+func (dr *diskReader) ReadByte() (byte, error) {
+	b, err := dr.r.ReadByte()
+	if err == nil {
+		dr.remaining--
+	}
+	return b, err
+}
+
+// readLength reads a uvarint length or count. Every byte string and list entry
+// takes at least one byte, so a value above the remaining size is corrupt and
+// yields ErrInvalidDiskIndex.
+func (dr *diskReader) readLength() (int, error) {
+	n, err := binary.ReadUvarint(dr)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(dr.remaining) {
+		return 0, ErrInvalidDiskIndex
+	}
+	return int(n), nil
+}
+
+// readString reads a uvarint length followed by that many bytes
+func (dr *diskReader) readString() (string, error) {
+	n, err := dr.readLength()
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(dr.r, buf); err != nil {
+		return "", err
+	}
+	dr.remaining -= int64(n)
+	return string(buf), nil
+}
+
+// countingWriter tracks the number of bytes written and the first error seen
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) write(p []byte) {
+	if cw.err != nil {
+		return
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func (cw *countingWriter) writeString(s string) {
+	cw.write([]byte(s))
+}
+
+func (cw *countingWriter) writeUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	cw.write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+// lruCache is a fixed-capacity cache of match lists that evicts the least
+// recently used entry when full
+type lruCache struct {
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+	hits     uint64
+	misses   uint64
+}
+
+// lruEntry is the value stored in each element of lruCache.order
+type lruEntry struct {
+	key   string
+	value []string
+}
+
+// newLRUCache creates an empty cache holding at most capacity entries
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached value for key and marks it as most recently used
+func (c *lruCache) get(key string) ([]string, bool) {
+	if elem, ok := c.entries[key]; ok {
+		c.hits++
+		c.order.MoveToFront(elem)
+		return elem.Value.(*lruEntry).value, true
+	}
+	c.misses++
+	return nil, false
+}
+
+// put stores value under key, evicting the least recently used entry if needed
+func (c *lruCache) put(key string, value []string) {
+	if c.capacity <= 0 {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*lruEntry).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value})
+}
+
+// reset drops every entry while keeping the hit and miss counters
+func (c *lruCache) reset() {
+	c.order.Init()
+	clear(c.entries)
+}
+
+// len returns the number of cached entries
+func (c *lruCache) len() int {
+	return c.order.Len()
+}
@@ -0,0 +1,50 @@
+package main
+
+// StaticSearchTreeT is a prefix search tree whose words each carry a payload of
+// type V, so searches can return the associated values instead of the words.
+type StaticSearchTreeT[V any] struct {
+	words  *StaticSearchTree
+	values map[string]V
+}
+
+// NewStaticSearchTreeT creates a tree from a map of words to their payloads
+func NewStaticSearchTreeT[V any](entries map[string]V, opts ...Option) *StaticSearchTreeT[V] {
+	words := make([]string, 0, len(entries))
+	for word := range entries {
+		words = append(words, word)
+	}
+	sst := NewStaticSearchTree(words, opts...)
+	
+	// Searches return the stored form, so payloads are keyed by it
+	values := make(map[string]V, len(entries))
+	for word, value := range entries {
+		values[sst.opts.storedForm(word)] = value
+	}
+	return &StaticSearchTreeT[V]{
+		words:  sst,
+		values: values,
+	}
+}
+
+// Search performs a prefix search and returns the matching words
+func (t *StaticSearchTreeT[V]) Search(query string) []string {
+	return t.words.Search(query)
+}
+
+// SearchValues performs a prefix search and returns the payloads of the matching
+// words, in the same order as Search
+func (t *StaticSearchTreeT[V]) SearchValues(query string) []V {
+	matches := t.words.Search(query)
+	values := make([]V, len(matches))
+	for i, word := range matches {
+		values[i] = t.values[word]
+	}
+	return values
+}
+
+// Value returns the payload stored for word, which may be given as indexed or in
+// its stored form
+func (t *StaticSearchTreeT[V]) Value(word string) (V, bool) {
+	value, ok := t.values[t.words.opts.storedForm(word)]
+	return value, ok
+}
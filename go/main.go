@@ -1,121 +1,3431 @@
 package main
 
 import (
+	"bufio"
+	"compress/gzip"
+	"container/heap"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+	
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// StaticSearchTree represents a precomputed search tree for efficient prefix matching.
+// Search, SearchWithLimit, Size and GetAllPrefixes treat a nil *StaticSearchTree,
+// and a zero StaticSearchTree, as an empty tree instead of panicking; use the
+// constructors for everything else.
+type StaticSearchTree struct {
+	mu       sync.RWMutex
+	tree     map[string][]string
+	words    map[string]struct{}
+	radix    *radixNode
+	opts     Options
+	pipeline pipeline
+	clicks   map[string]int
+	weights  map[string]int
+	tags     map[string][]string
+	version  uint64
+	
+	// suffixes indexes the reversed words for SearchSuffix when SuffixIndex is set
+	suffixes *StaticSearchTree
+	
+	// bloom rejects prefixes that are definitely not in the prefix map when
+	// UseBloomFilter is set
+	bloom *bloomFilter
+	
+	// insertion ranks words by when they were first supplied, for
+	// PreserveInsertionOrder; nextInsertion is the rank of the next new word
+	insertion     map[string]int
+	nextInsertion int
+	
+	// cacheMu guards the result cache, which concurrent readers share
+	cacheMu      sync.Mutex
+	cache        *lruCache
+	cacheVersion uint64
+}
+
+// PrefixSearcher is implemented by every index that answers prefix queries
+type PrefixSearcher interface {
+	Search(query string) []string
+}
+
+// Backend selects the internal representation of a StaticSearchTree
+type Backend int
+
+const (
+	// BackendRadix stores a compressed trie and collects the words below the
+	// query's node on demand. Memory is proportional to the total word length,
+	// while a search costs O(L + matches·log matches). It is the default.
+	BackendRadix Backend = iota
+	
+	// BackendPrefixMap precomputes the match list of every prefix. Lookups are a
+	// single map access, at the cost of O(N·L) keys each holding up to N words.
+	BackendPrefixMap
+)
+
+// MatchMode selects how Search compares the query with the indexed words
+type MatchMode int
+
+const (
+	// MatchPrefix returns the words starting with the query
+	MatchPrefix MatchMode = iota
+	
+	// MatchExact returns the one word equal to the query, if any, preferring the
+	// query's own casing among words that normalize alike
+	MatchExact
+	
+	// MatchContains returns the words containing the query anywhere, scanning
+	// every indexed word like SearchSubstring
+	MatchContains
+)
+
+// Options configures how a StaticSearchTree indexes and returns words
+type Options struct {
+	// WhitespaceInsensitiveDedup collapses results that differ only in separator
+	// characters (whitespace, '-', '_' and '.') into a single representative
+	WhitespaceInsensitiveDedup bool
+	
+	// TrimSpace removes leading and trailing whitespace from words and queries
+	TrimSpace bool
+	
+	// RepeatFolding collapses runs of a repeated character to a single character
+	RepeatFolding bool
+	
+	// CapacityHint pre-sizes the prefix map, with BackendPrefixMap, for the
+	// expected number of prefixes
+	CapacityHint int
+	
+	// Backend selects the internal representation; the zero value is BackendRadix
+	Backend Backend
+	
+	// ClickOrdering ranks search results by the click counts loaded with LoadClicks
+	ClickOrdering bool
+	
+	// CaseSensitive disables lowercasing, so words such as "API" and "api" are distinct
+	CaseSensitive bool
+	
+	// UnicodeFolding normalizes words and queries to NFC and applies full Unicode
+	// case folding instead of plain lowercasing, so composed and decomposed
+	// accents match and "ß" matches "ss"
+	UnicodeFolding bool
+	
+	// MaxPrefixLength caps the length in characters of the prefixes stored by the
+	// prefix map backend, so a pathologically long word adds at most that many
+	// entries. Longer queries filter the matches of their capped prefix. Zero
+	// means unlimited; the radix backend stores each character once regardless.
+	MaxPrefixLength int
+	
+	// DeleteExactCase makes Delete remove only the exact casing given, so deleting
+	// "apple" keeps "Apple". Words differing only in case are always indexed and
+	// returned as distinct entries; without the option Delete removes them all.
+	DeleteExactCase bool
+	
+	// ResultCacheSize enables an LRU cache of up to this many results for the
+	// expensive search modes (fuzzy, substring and regular expression)
+	ResultCacheSize int
+	
+	// TokenizeOnWhitespace also indexes each word from the start of every token,
+	// so "New York City" matches "york" and "city" as well as "new"
+	TokenizeOnWhitespace bool
+	
+	// TokenDelimiters lists characters that separate tokens in addition to
+	// whitespace; setting it enables tokenization on its own
+	TokenDelimiters string
+	
+	// SuffixIndex also indexes every word reversed, so SearchSuffix answers with a
+	// lookup instead of scanning all words
+	SuffixIndex bool
+	
+	// PreserveInsertionOrder returns matches in the order the words were first
+	// supplied instead of lexicographically, e.g. for a curated priority list
+	PreserveInsertionOrder bool
+	
+	// MinQueryLength makes searches return no matches for queries shorter than
+	// this many characters after normalization, so a single letter does not flood
+	// the caller with results. Zero disables the limit.
+	MinQueryLength int
+	
+	// NormalizeWhitespace trims words and queries and collapses every internal run
+	// of whitespace to a single space. Unlike TrimSpace it also rewrites the stored
+	// words, so "  apple " and "apple" become one entry.
+	NormalizeWhitespace bool
+	
+	// StopWords lists tokens, matched case-insensitively, that tokenization does
+	// not index as search terms of their own, such as "the" or "of". A word is
+	// still found from its start and from its other tokens.
+	StopWords []string
+	
+	// FoldAccents strips diacritics from words and queries before matching, so
+	// "cafe" finds "café"; results keep their accents. It combines with any of
+	// the case handling options.
+	FoldAccents bool
+	
+	// UseBloomFilter keeps a bloom filter of the stored prefixes so the prefix map
+	// backend rejects most prefixes that match nothing without a map lookup. False
+	// positives fall through to the map, so results are unaffected.
+	UseBloomFilter bool
+	
+	// DefaultLimit truncates the results of Search, SearchIter and SearchBatch to
+	// this many matches, so no single call returns an unbounded slice. The other
+	// search methods are not capped, and SearchWithLimit overrides it per call.
+	// Zero means unlimited.
+	DefaultLimit int
+	
+	// MatchMode selects prefix, whole-word or substring matching for Search and
+	// the methods built on it; the zero value is MatchPrefix. Methods defined in
+	// terms of the matched prefix, such as SearchHighlighted, GetCompletions,
+	// NextChars and SearchWithBucket, always match by prefix.
+	MatchMode MatchMode
+}
+
+// Option modifies the Options used to build a StaticSearchTree
+type Option func(*Options)
+
+// WithWhitespaceInsensitiveDedup treats words differing only in separator characters
+// as one result, returning the lexicographically smallest variant
+func WithWhitespaceInsensitiveDedup(enabled bool) Option {
+	return func(o *Options) {
+		o.WhitespaceInsensitiveDedup = enabled
+	}
+}
+
+// WithTrimSpace trims leading and trailing whitespace from words and queries
+func WithTrimSpace(enabled bool) Option {
+	return func(o *Options) {
+		o.TrimSpace = enabled
+	}
+}
+
+// WithRepeatFolding collapses every run of a repeated character to one occurrence
+// in both words and queries, so "heeello" and "hellllo" find "hello". This is
+// deliberately aggressive: runs of two are folded too, which is what lets an
+// over-typed query reach a word with a double letter, but it also merges words
+// that differ only in doubling, such as "bok" and "book".
+func WithRepeatFolding(enabled bool) Option {
+	return func(o *Options) {
+		o.RepeatFolding = enabled
+	}
+}
+
+// WithCapacityHint allocates the prefix map with room for the given number of
+// prefixes, avoiding rehashing during build when the count is known in advance
+func WithCapacityHint(prefixes int) Option {
+	return func(o *Options) {
+		o.CapacityHint = prefixes
+	}
+}
+
+// WithBackend selects the internal representation of the tree
+func WithBackend(backend Backend) Option {
+	return func(o *Options) {
+		o.Backend = backend
+	}
+}
+
+// WithClickOrdering ranks search results by observed clicks (see LoadClicks), most
+// clicked first, falling back to alphabetical order for words with equal clicks
+func WithClickOrdering() Option {
+	return func(o *Options) {
+		o.ClickOrdering = true
+	}
+}
+
+// WithCaseSensitive matches words and queries case-sensitively instead of
+// lowercasing them
+func WithCaseSensitive(enabled bool) Option {
+	return func(o *Options) {
+		o.CaseSensitive = enabled
+	}
+}
+
+// WithUnicodeFolding normalizes words and queries to NFC and folds case with the
+// full Unicode rules
+func WithUnicodeFolding(enabled bool) Option {
+	return func(o *Options) {
+		o.UnicodeFolding = enabled
+	}
+}
+
+// WithMaxPrefixLength caps the length of the stored prefixes at maxLen characters
+func WithMaxPrefixLength(maxLen int) Option {
+	return func(o *Options) {
+		o.MaxPrefixLength = maxLen
+	}
+}
+
+// WithDeleteExactCase makes Delete remove only the exact casing of a word
+func WithDeleteExactCase(enabled bool) Option {
+	return func(o *Options) {
+		o.DeleteExactCase = enabled
+	}
+}
+
+// WithResultCache caches up to entries results of the expensive search modes
+func WithResultCache(entries int) Option {
+	return func(o *Options) {
+		o.ResultCacheSize = entries
+	}
+}
+
+// WithTokenization indexes every token of a word, splitting on whitespace and on
+// any of the extra delimiter characters
+func WithTokenization(delimiters string) Option {
+	return func(o *Options) {
+		o.TokenizeOnWhitespace = true
+		o.TokenDelimiters = delimiters
+	}
+}
+
+// WithSuffixIndex builds a reversed index of the words for SearchSuffix
+func WithSuffixIndex(enabled bool) Option {
+	return func(o *Options) {
+		o.SuffixIndex = enabled
+	}
+}
+
+// WithPreserveInsertionOrder returns matches in the order the words were supplied
+func WithPreserveInsertionOrder(enabled bool) Option {
+	return func(o *Options) {
+		o.PreserveInsertionOrder = enabled
+	}
+}
+
+// WithMinQueryLength returns no matches for queries shorter than minLen characters
+func WithMinQueryLength(minLen int) Option {
+	return func(o *Options) {
+		o.MinQueryLength = minLen
+	}
+}
+
+// WithNormalizeWhitespace trims and collapses whitespace in words and queries
+func WithNormalizeWhitespace(enabled bool) Option {
+	return func(o *Options) {
+		o.NormalizeWhitespace = enabled
+	}
+}
+
+// WithStopWords skips the given tokens when indexing the tokens of a word
+func WithStopWords(words ...string) Option {
+	return func(o *Options) {
+		o.StopWords = words
+	}
+}
+
+// WithFoldAccents matches words and queries with their diacritics removed
+func WithFoldAccents(enabled bool) Option {
+	return func(o *Options) {
+		o.FoldAccents = enabled
+	}
+}
+
+// WithBloomFilter rejects absent prefixes with a bloom filter before the map lookup
+func WithBloomFilter(enabled bool) Option {
+	return func(o *Options) {
+		o.UseBloomFilter = enabled
+	}
+}
+
+// WithDefaultLimit caps the number of matches Search and its batched and
+// iterator forms return
+func WithDefaultLimit(limit int) Option {
+	return func(o *Options) {
+		o.DefaultLimit = limit
+	}
+}
+
+// WithMatchMode selects how Search matches the query against the words
+func WithMatchMode(mode MatchMode) Option {
+	return func(o *Options) {
+		o.MatchMode = mode
+	}
+}
+
+// NewStaticSearchTree creates a new static search tree from a list of words
+func NewStaticSearchTree(words []string, opts ...Option) *StaticSearchTree {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return NewStaticSearchTreeWithOptions(words, options)
+}
+
+// NewStaticSearchTreeWithOptions creates a new static search tree from a list of
+// words configured by a complete Options value
+func NewStaticSearchTreeWithOptions(words []string, opts Options) *StaticSearchTree {
+	sst := newTree(opts)
+	sst.build(words)
+	return sst
+}
+
+// NewStaticSearchTreeFromReader builds a tree from the newline-delimited words of
+// r without first collecting the input into a caller-owned slice. Words are
+// trimmed of surrounding whitespace and blank lines are skipped.
+func NewStaticSearchTreeFromReader(r io.Reader, opts ...Option) (*StaticSearchTree, error) {
+	var words []string
+	err := scanWords(r, func(word string) {
+		words = append(words, word)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewStaticSearchTree(words, opts...), nil
+}
+
+// NewStaticSearchTreeFromGzip builds a tree like NewStaticSearchTreeFromReader
+// from gzip-compressed newline-delimited words, such as a .txt.gz dictionary,
+// decompressing as it reads. Invalid gzip data is reported like a scan error.
+func NewStaticSearchTreeFromGzip(r io.Reader, opts ...Option) (*StaticSearchTree, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return NewStaticSearchTreeFromReader(zr, opts...)
+}
+
+// NewStaticSearchTreeFromReaders builds one tree from the words of several
+// readers, read in order like NewStaticSearchTreeFromReader. A word appearing in
+// more than one reader is indexed once. A scan failure is reported together with
+// the index of the reader that caused it.
+func NewStaticSearchTreeFromReaders(readers []io.Reader, opts ...Option) (*StaticSearchTree, error) {
+	seen := make(map[string]bool)
+	var words []string
+	for i, r := range readers {
+		err := scanWords(r, func(word string) {
+			if !seen[word] {
+				seen[word] = true
+				words = append(words, word)
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reader %d: %w", i, err)
+		}
+	}
+	return NewStaticSearchTree(words, opts...), nil
+}
+
+// NewStaticSearchTreeWithWeights creates a tree from the words of weights and
+// keeps their popularity scores for SearchByWeight
+func NewStaticSearchTreeWithWeights(weights map[string]int, opts ...Option) *StaticSearchTree {
+	words := make([]string, 0, len(weights))
+	for word := range weights {
+		words = append(words, word)
+	}
+	sst := NewStaticSearchTree(words, opts...)
+	sst.weights = maps.Clone(weights)
+	return sst
+}
+
+// NewStaticSearchTreeWithTags creates a tree from the words of tags and keeps the
+// tags of each word, e.g. its categories, for SearchWithTag
+func NewStaticSearchTreeWithTags(tags map[string][]string, opts ...Option) *StaticSearchTree {
+	words := make([]string, 0, len(tags))
+	for word := range tags {
+		words = append(words, word)
+	}
+	sst := NewStaticSearchTree(words, opts...)
+	sst.tags = make(map[string][]string, len(tags))
+	for word, wordTags := range tags {
+		stored := sst.opts.storedForm(word)
+		sst.tags[stored] = append(sst.tags[stored], wordTags...)
+	}
+	return sst
+}
+
+// scanWords calls fn with each trimmed, non-blank line of r
+func scanWords(r io.Reader, fn func(word string)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" {
+			fn(word)
+		}
+	}
+	return scanner.Err()
+}
+
+// ErrEmptyWord is returned by NewStaticSearchTreeStrict for an empty word
+var ErrEmptyWord = errors.New("empty word")
+
+// ErrControlCharacter is returned by NewStaticSearchTreeStrict for a word
+// containing a control character
+var ErrControlCharacter = errors.New("word contains control character")
+
+// NewStaticSearchTreeStrict creates a tree like NewStaticSearchTree but rejects
+// empty words and words containing control characters, so bad data is caught
+// before it reaches the index. The error identifies the first offending word.
+func NewStaticSearchTreeStrict(words []string, opts ...Option) (*StaticSearchTree, error) {
+	for i, word := range words {
+		if word == "" {
+			return nil, fmt.Errorf("word %d: %w", i, ErrEmptyWord)
+		}
+		if strings.IndexFunc(word, unicode.IsControl) >= 0 {
+			return nil, fmt.Errorf("word %d %q: %w", i, word, ErrControlCharacter)
+		}
+	}
+	return NewStaticSearchTree(words, opts...), nil
+}
+
+// newTree creates an empty tree configured with the given options
+func newTree(opts Options) *StaticSearchTree {
+	sst := &StaticSearchTree{
+		opts:     opts,
+		pipeline: buildPipeline(opts),
+	}
+	if opts.Backend == BackendRadix {
+		sst.radix = &radixNode{}
+	} else {
+		sst.tree = make(map[string][]string, max(opts.CapacityHint, 0))
+		sst.words = make(map[string]struct{})
+		if opts.UseBloomFilter {
+			sst.bloom = newBloomFilter(opts.CapacityHint)
+		}
+	}
+	if opts.ResultCacheSize > 0 {
+		sst.cache = newLRUCache(opts.ResultCacheSize)
+	}
+	if opts.SuffixIndex {
+		// The suffix index is a plain tree whose pipeline finally reverses the
+		// normalized word, so its prefixes are the suffixes of the words
+		inner := opts
+		inner.SuffixIndex = false
+		inner.ResultCacheSize = 0
+		inner.TokenizeOnWhitespace = false
+		inner.TokenDelimiters = ""
+		sst.suffixes = newTree(inner)
+		sst.suffixes.pipeline = append(sst.suffixes.pipeline, transform{"reverse", reverseRunes})
+	}
+	return sst
+}
+
+// reverseRunes returns s with its runes in reverse order
+func reverseRunes(s string) string {
+	runes := []rune(s)
+	slices.Reverse(runes)
+	return string(runes)
+}
+
+// transform is a single named step of the normalization pipeline
+type transform struct {
+	name  string
+	apply func(string) string
+}
+
+// pipeline is the ordered list of normalization steps applied to words and queries
+type pipeline []transform
+
+// buildPipeline returns the normalization steps for the given options
+func buildPipeline(opts Options) pipeline {
+	var p pipeline
+	if opts.NormalizeWhitespace {
+		p = append(p, transform{"collapse-space", collapseWhitespace})
+	}
+	if opts.TrimSpace {
+		p = append(p, transform{"trim", strings.TrimSpace})
+	}
+	if opts.UnicodeFolding {
+		p = append(p, transform{"nfc", norm.NFC.String})
+	}
+	if opts.FoldAccents {
+		p = append(p, transform{"fold-accents", foldAccents})
+	}
+	switch {
+	case opts.CaseSensitive:
+	case opts.UnicodeFolding:
+		p = append(p, transform{"casefold", foldCase})
+	default:
+		p = append(p, transform{"lowercase", strings.ToLower})
+	}
+	if opts.RepeatFolding {
+		p = append(p, transform{"fold-repeats", foldRepeats})
+	}
+	return p
+}
+
+// collapseWhitespace trims s and replaces each internal run of whitespace with a
+// single space
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// storedForm returns word as the tree stores it, which differs from the word
+// supplied only with NormalizeWhitespace
+func (o Options) storedForm(word string) string {
+	if o.NormalizeWhitespace {
+		return collapseWhitespace(word)
+	}
+	return word
+}
+
+// foldAccents decomposes s, drops the combining marks and recomposes what is left,
+// turning "café" into "cafe"
+func foldAccents(s string) string {
+	stripped := strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(s))
+	return norm.NFC.String(stripped)
+}
+
+// foldCase applies full Unicode case folding to s. A Caser keeps state between
+// calls, so a fresh one is used each time to stay safe for concurrent searches.
+func foldCase(s string) string {
+	return cases.Fold().String(s)
+}
+
+// foldRepeats collapses each run of identical runes in s to a single rune
+func foldRepeats(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	prev := rune(-1)
+	for _, r := range s {
+		if r != prev {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// normalize runs s through every step of the pipeline
+func (p pipeline) normalize(s string) string {
+	for _, t := range p {
+		s = t.apply(s)
+	}
+	return s
+}
+
+// rankWords records the insertion rank of each word not seen before and returns
+// the words in order of rank without duplicates
+func (sst *StaticSearchTree) rankWords(words []string) []string {
+	if sst.insertion == nil {
+		sst.insertion = make(map[string]int)
+	}
+	seen := make(map[string]bool, len(words))
+	unique := make([]string, 0, len(words))
+	for _, word := range words {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		unique = append(unique, word)
+		if _, ranked := sst.insertion[word]; !ranked {
+			sst.insertion[word] = sst.nextInsertion
+			sst.nextInsertion++
+		}
+	}
+	sort.SliceStable(unique, func(i, j int) bool { return sst.insertion[unique[i]] < sst.insertion[unique[j]] })
+	return unique
+}
+
+// sortMatches orders a match list lexicographically, or by insertion rank with
+// PreserveInsertionOrder
+func (sst *StaticSearchTree) sortMatches(matches []string) {
+	if sst.opts.PreserveInsertionOrder {
+		sort.SliceStable(matches, func(i, j int) bool { return sst.insertion[matches[i]] < sst.insertion[matches[j]] })
+		return
+	}
+	sort.Strings(matches)
+}
+
+// matchPosition returns where word belongs in a match list ordered by
+// sortMatches, and whether it is already there
+func (sst *StaticSearchTree) matchPosition(matches []string, word string) (int, bool) {
+	var pos int
+	if sst.opts.PreserveInsertionOrder {
+		rank := sst.insertion[word]
+		pos = sort.Search(len(matches), func(i int) bool { return sst.insertion[matches[i]] >= rank })
+	} else {
+		pos = sort.SearchStrings(matches, word)
+	}
+	return pos, pos < len(matches) && matches[pos] == word
+}
+
+// normalize runs s through the tree's normalization pipeline
+func (sst *StaticSearchTree) normalize(s string) string {
+	return sst.pipeline.normalize(s)
+}
+
+// build constructs the static search tree by precomputing all prefix combinations
+func (sst *StaticSearchTree) build(words []string) {
+	sst.version++
+	
+	if sst.opts.NormalizeWhitespace {
+		stored := make([]string, len(words))
+		for i, word := range words {
+			stored[i] = sst.opts.storedForm(word)
+		}
+		words = stored
+	}
+	
+	// Sort words to ensure consistent ordering, unless the order they were
+	// supplied in is to be kept
+	if sst.opts.PreserveInsertionOrder {
+		words = sst.rankWords(words)
+	} else {
+		sort.Strings(words)
+	}
+	
+	if sst.suffixes != nil {
+		sst.suffixes.build(words)
+	}
+	
+	if sst.radix != nil {
+		for _, word := range words {
+			for _, key := range tokenKeys(sst.normalize(word), sst.opts) {
+				sst.radix.insert(key, word)
+			}
+		}
+		return
+	}
+	
+	// Normalize each word once up front and order the keys it is indexed under,
+	// so the words matching any prefix form a contiguous run
+	var keys []string
+	var owners []int
+	for i, word := range words {
+		indexed := tokenKeys(sst.normalize(word), sst.opts)
+		for _, key := range indexed {
+			keys = append(keys, key)
+			owners = append(owners, i)
+		}
+		if len(indexed) > 0 {
+			sst.words[word] = struct{}{}
+		}
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return keys[order[a]] < keys[order[b]] })
+	sorted := make([]string, len(order))
+	for j, i := range order {
+		sorted[j] = keys[i]
+	}
+	
+	for j, key := range sorted {
+		// Prefixes shared with the previous key were already stored by this pass
+		shared := 0
+		if j > 0 {
+			shared = commonPrefixLen(sorted[j-1], key)
+		}
+		for i := shared + 1; i <= len(indexedPrefix(key, sst.opts.MaxPrefixLength)); i++ {
+			prefix := key[:i]
+			
+			// The run of keys starting with prefix begins here; binary search its end
+			end := j + sort.Search(len(sorted)-j, func(n int) bool {
+				return !strings.HasPrefix(sorted[j+n], prefix)
+			})
+			
+			// Collect the matches in word order, dropping duplicate words
+			run := make([]int, 0, end-j)
+			for _, k := range order[j:end] {
+				run = append(run, owners[k])
+			}
+			sort.Ints(run)
+			matches := make([]string, 0, len(run))
+			for _, w := range run {
+				if len(matches) == 0 || matches[len(matches)-1] != words[w] {
+					matches = append(matches, words[w])
+				}
+			}
+			
+			// Merge with matches stored before this build
+			if existing, exists := sst.tree[prefix]; exists {
+				sst.tree[prefix] = mergeDeduplicate(existing, matches)
+			} else {
+				sst.addPrefix(prefix)
+				sst.tree[prefix] = matches
+			}
+		}
+	}
+}
+
+// NewStaticSearchTreeWithDeadline indexes words in order until the deadline passes
+// and returns the (possibly partial) tree along with the number of words that were
+// indexed. The remaining words, words[indexed:], can be added later via InsertAll.
+func NewStaticSearchTreeWithDeadline(words []string, deadline time.Time, opts ...Option) (*StaticSearchTree, int) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	sst := newTree(options)
+	indexed := 0
+	for _, word := range words {
+		if !time.Now().Before(deadline) {
+			break
+		}
+		sst.insert(word)
+		indexed++
+	}
+	return sst, indexed
+}
+
+// NewStaticSearchTreeParallel builds the same tree as NewStaticSearchTree, but
+// splits the sorted words into contiguous chunks indexed by up to workers
+// goroutines and merges the partial prefix maps. Because the chunks are merged in
+// word order the result does not depend on the number of workers. The radix
+// backend and PreserveInsertionOrder are built serially.
+func NewStaticSearchTreeParallel(words []string, workers int, opts ...Option) *StaticSearchTree {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	sst := newTree(options)
+	workers = min(workers, len(words))
+	if workers <= 1 || sst.radix != nil || options.PreserveInsertionOrder {
+		sst.build(words)
+		return sst
+	}
+	
+	// Words are sorted in their stored form, which is what the chunks are built from
+	if options.NormalizeWhitespace {
+		stored := make([]string, len(words))
+		for i, word := range words {
+			stored[i] = options.storedForm(word)
+		}
+		words = stored
+	}
+	sort.Strings(words)
+	inner := options
+	inner.SuffixIndex = false
+	inner.ResultCacheSize = 0
+	inner.UseBloomFilter = false
+	parts := make([]*StaticSearchTree, workers)
+	var wg sync.WaitGroup
+	for i := range parts {
+		chunk := words[i*len(words)/workers : (i+1)*len(words)/workers]
+		parts[i] = newTree(inner)
+		wg.Add(1)
+		go func(part *StaticSearchTree) {
+			defer wg.Done()
+			part.build(chunk)
+		}(parts[i])
+	}
+	if sst.suffixes != nil {
+		sst.suffixes.build(words)
+	}
+	wg.Wait()
+	
+	// Every chunk holds words sorted after those of the previous one, so merging
+	// the lists in chunk order keeps them sorted. Only prefixes spanning a chunk
+	// boundary need merging at all.
+	sst.version++
+	for _, part := range parts {
+		for prefix, matches := range part.tree {
+			if existing, exists := sst.tree[prefix]; exists {
+				sst.tree[prefix] = mergeDeduplicate(existing, matches)
+			} else {
+				sst.addPrefix(prefix)
+				sst.tree[prefix] = matches
+			}
+		}
+		for word := range part.words {
+			sst.words[word] = struct{}{}
+		}
+	}
+	return sst
+}
+
+// Insert adds a single word to the tree, updating only the prefixes of that word
+// instead of rebuilding. It reports whether the word was added; inserting a word
+// that is already indexed is a no-op.
+func (sst *StaticSearchTree) Insert(word string) bool {
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	return sst.insert(word)
+}
+
+// InsertAll adds words to the tree and returns how many of them were not already indexed
+func (sst *StaticSearchTree) InsertAll(words []string) int {
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	added := 0
+	for _, word := range words {
+		if sst.insert(word) {
+			added++
+		}
+	}
+	return added
+}
+
+// insert adds word to the match list of each of its prefixes, keeping the lists
+// in the same sorted order that build produces. Lists are replaced rather than
+// modified in place so that snapshots sharing them are unaffected.
+func (sst *StaticSearchTree) insert(word string) bool {
+	word = sst.opts.storedForm(word)
+	if sst.opts.PreserveInsertionOrder {
+		sst.rankWords([]string{word})
+	}
+	added := false
+	for _, key := range tokenKeys(sst.normalize(word), sst.opts) {
+		if sst.radix != nil {
+			if sst.radix.insert(key, word) {
+				added = true
+			}
+			continue
+		}
+		for i := 1; i <= len(indexedPrefix(key, sst.opts.MaxPrefixLength)); i++ {
+			prefix := key[:i]
+			matches, exists := sst.tree[prefix]
+			pos, present := sst.matchPosition(matches, word)
+			if present {
+				continue
+			}
+			if !exists {
+				sst.addPrefix(prefix)
+			}
+			updated := make([]string, 0, len(matches)+1)
+			updated = append(updated, matches[:pos]...)
+			updated = append(updated, word)
+			updated = append(updated, matches[pos:]...)
+			sst.tree[prefix] = updated
+			added = true
+		}
+		sst.words[word] = struct{}{}
+	}
+	if added {
+		sst.version++
+		if sst.suffixes != nil {
+			sst.suffixes.insert(word)
+		}
+	}
+	return added
+}
+
+// Compact reallocates the match lists, and the trie nodes of the radix backend,
+// at their exact lengths and drops prefixes left without matches, releasing the
+// capacity that many deletions leave behind. Search results are unchanged.
+func (sst *StaticSearchTree) Compact() {
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	sst.compact()
+}
+
+// compact implements Compact; the caller must hold the lock. Lists are replaced
+// rather than shrunk in place so that snapshots sharing them are unaffected.
+func (sst *StaticSearchTree) compact() {
+	if sst.suffixes != nil {
+		sst.suffixes.compact()
+	}
+	if sst.radix != nil {
+		sst.radix.compact()
+		return
+	}
+	
+	// Maps never shrink, so the prefix map and word set are copied into new ones
+	tree := make(map[string][]string, len(sst.tree))
+	for prefix, matches := range sst.tree {
+		if len(matches) == 0 {
+			continue
+		}
+		if cap(matches) > len(matches) {
+			matches = append(make([]string, 0, len(matches)), matches...)
+		}
+		tree[prefix] = matches
+	}
+	sst.tree = tree
+	sst.words = maps.Clone(sst.words)
+	if sst.bloom != nil {
+		// Deleted prefixes would otherwise stay in the filter
+		sst.rebuildBloom()
+	}
+}
+
+// Clear removes every word, keeping the allocated prefix map for reuse
+func (sst *StaticSearchTree) Clear() {
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	sst.clear()
+}
+
+// Rebuild replaces the indexed words with words in place, reusing the existing
+// storage instead of allocating a new tree
+func (sst *StaticSearchTree) Rebuild(words []string) {
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	sst.clear()
+	sst.build(words)
+}
+
+// clear implements Clear; the caller must hold the lock. Snapshots copy the map
+// itself, so clearing it in place does not affect them.
+func (sst *StaticSearchTree) clear() {
+	sst.version++
+	sst.insertion = nil
+	sst.nextInsertion = 0
+	if sst.suffixes != nil {
+		sst.suffixes.clear()
+	}
+	if sst.radix != nil {
+		sst.radix = &radixNode{}
+		return
+	}
+	clear(sst.tree)
+	clear(sst.words)
+	if sst.bloom != nil {
+		sst.bloom = newBloomFilter(sst.opts.CapacityHint)
+	}
+}
+
+// Delete removes word from every prefix it is listed under and drops prefixes left
+// without matches. Like Search it is case-insensitive, so every indexed word that
+// normalizes to the same form as word is removed, unless DeleteExactCase is
+// set. It reports whether anything was.
+func (sst *StaticSearchTree) Delete(word string) bool {
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	return sst.remove(word)
+}
+
+// remove implements Delete; the caller must hold the lock
+func (sst *StaticSearchTree) remove(word string) bool {
+	word = sst.opts.storedForm(word)
+	key := sst.normalize(word)
+	if key == "" {
+		return false
+	}
+	victim := func(candidate string) bool {
+		if sst.opts.DeleteExactCase {
+			return candidate == word
+		}
+		return sst.normalize(candidate) == key
+	}
+	if sst.radix != nil {
+		removed := false
+		for _, indexed := range tokenKeys(key, sst.opts) {
+			if sst.radix.remove(indexed, victim) {
+				removed = true
+			}
+		}
+		if removed {
+			sst.version++
+			if sst.suffixes != nil {
+				sst.suffixes.remove(word)
+			}
+		}
+		return removed
+	}
+	
+	victims := make(map[string]bool)
+	candidates, _ := sst.lookup(key)
+	for _, candidate := range candidates {
+		if sst.normalize(candidate) == key && victim(candidate) {
+			victims[candidate] = true
+		}
+	}
+	if len(victims) == 0 {
+		return false
+	}
+	
+	for word := range victims {
+		delete(sst.words, word)
+	}
+	for _, indexed := range tokenKeys(key, sst.opts) {
+		for i := 1; i <= len(indexedPrefix(indexed, sst.opts.MaxPrefixLength)); i++ {
+			prefix := indexed[:i]
+			remaining := make([]string, 0, len(sst.tree[prefix]))
+			for _, candidate := range sst.tree[prefix] {
+				if !victims[candidate] {
+					remaining = append(remaining, candidate)
+				}
+			}
+			if len(remaining) == 0 {
+				delete(sst.tree, prefix)
+			} else {
+				sst.tree[prefix] = remaining
+			}
+		}
+	}
+	sst.version++
+	if sst.suffixes != nil {
+		sst.suffixes.remove(word)
+	}
+	return true
+}
+
+// Merge returns a new tree answering searches for the union of the words of sst
+// and other, configured like sst. Neither input is modified. When both trees use
+// the prefix map with the same options their match lists are merged prefix by
+// prefix; otherwise the words of other are inserted one at a time.
+func (sst *StaticSearchTree) Merge(other *StaticSearchTree) *StaticSearchTree {
+	sst.mu.RLock()
+	merged := sst.copyTree()
+	sst.mu.RUnlock()
+	if merged.opts.ResultCacheSize > 0 {
+		merged.cache = newLRUCache(merged.opts.ResultCacheSize)
+	}
+	
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	merged.merge(other)
+	return merged
+}
+
+// merge adds the words of other to sst; the caller must hold the read lock of
+// other and sst must not be shared yet
+func (sst *StaticSearchTree) merge(other *StaticSearchTree) {
+	if sst.radix != nil || other.radix != nil || !reflect.DeepEqual(sst.opts, other.opts) || sst.opts.PreserveInsertionOrder {
+		words := other.indexedWords()
+		if other.opts.PreserveInsertionOrder {
+			sort.SliceStable(words, func(i, j int) bool { return other.insertion[words[i]] < other.insertion[words[j]] })
+		}
+		for _, word := range words {
+			sst.insert(word)
+		}
+		return
+	}
+	
+	sst.version++
+	for prefix, matches := range other.tree {
+		// Lists are copy-on-write, so those only other has can be shared
+		if existing, exists := sst.tree[prefix]; exists {
+			combined := mergeDeduplicate(existing, matches)
+			sort.Strings(combined)
+			sst.tree[prefix] = combined
+		} else {
+			sst.addPrefix(prefix)
+			sst.tree[prefix] = matches
+		}
+	}
+	for word := range other.words {
+		sst.words[word] = struct{}{}
+	}
+	if sst.suffixes != nil {
+		sst.suffixes.merge(other.suffixes)
+	}
+}
+
+// mergeDeduplicate merges two slices and removes duplicates
+func mergeDeduplicate(slice1, slice2 []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	
+	// Add all items from both slices
+	for _, item := range slice1 {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	
+	for _, item := range slice2 {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	
+	return result
+}
+
+// MergeSearchResults merges already-sorted result slices, such as the per-shard
+// results of the same query, into one sorted slice without duplicates. It performs
+// a k-way merge rather than re-sorting the concatenation.
+func MergeSearchResults(results ...[]string) []string {
+	h := &mergeHeap{}
+	for _, r := range results {
+		if len(r) > 0 {
+			*h = append(*h, mergeCursor{items: r})
+		}
+	}
+	heap.Init(h)
+	
+	merged := []string{}
+	for h.Len() > 0 {
+		cur := &(*h)[0]
+		item := cur.items[cur.pos]
+		if len(merged) == 0 || merged[len(merged)-1] != item {
+			merged = append(merged, item)
+		}
+		cur.pos++
+		if cur.pos == len(cur.items) {
+			heap.Pop(h)
+		} else {
+			heap.Fix(h, 0)
+		}
+	}
+	return merged
+}
+
+// mergeCursor tracks the read position within one input of MergeSearchResults
+type mergeCursor struct {
+	items []string
+	pos   int
+}
+
+// mergeHeap is a min-heap of cursors ordered by their current item
+type mergeHeap []mergeCursor
+
+func (h mergeHeap) Len() int           { return len(h) }
+func (h mergeHeap) Less(i, j int) bool { return h[i].items[h[i].pos] < h[j].items[h[j].pos] }
+func (h mergeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)        { *h = append(*h, x.(mergeCursor)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// lookup returns the match list of an already-normalized prefix. With the prefix
+// map backend the stored slice itself is returned and must not be modified.
+func (sst *StaticSearchTree) lookup(prefix string) ([]string, bool) {
+	if sst.radix == nil {
+		stored := indexedPrefix(prefix, sst.opts.MaxPrefixLength)
+		if sst.bloom != nil && !sst.bloom.mayContain(stored) {
+			return nil, false
+		}
+		matches, exists := sst.tree[stored]
+		if stored != prefix {
+			matches = filterByPrefix(matches, prefix, sst.pipeline, sst.opts)
+			exists = len(matches) > 0
+		}
+		return matches, exists
+	}
+	if prefix == "" {
+		return nil, false
+	}
+	node := sst.radix.find(prefix)
+	if node == nil {
+		return nil, false
+	}
+	matches := node.collect(nil)
+	sst.sortMatches(matches)
+	// A tokenized word may be stored under several keys below the node
+	return slices.Compact(matches), true
+}
+
+// addPrefix records a prefix about to be added to the prefix map in the bloom
+// filter, if there is one, resizing the filter once it is full
+func (sst *StaticSearchTree) addPrefix(prefix string) {
+	if sst.bloom == nil {
+		return
+	}
+	if sst.bloom.full() {
+		sst.rebuildBloom()
+	}
+	sst.bloom.add(prefix)
+}
+
+// rebuildBloom replaces the bloom filter with one holding exactly the stored
+// prefixes, sized with room for as many again
+func (sst *StaticSearchTree) rebuildBloom() {
+	sst.bloom = newBloomFilter(2 * len(sst.tree))
+	for prefix := range sst.tree {
+		sst.bloom.add(prefix)
+	}
+}
+
+// indexedPrefix returns the part of an already-normalized key that the prefix map
+// stores, i.e. its first maxLen characters, or the whole key if maxLen is not positive
+func indexedPrefix(key string, maxLen int) string {
+	if maxLen <= 0 {
+		return key
+	}
+	count := 0
+	for i := range key {
+		if count == maxLen {
+			return key[:i]
+		}
+		count++
+	}
+	return key
+}
+
+// filterByPrefix returns the words indexed under a key starting with the
+// already-normalized prefix
+func filterByPrefix(words []string, prefix string, p pipeline, opts Options) []string {
+	var filtered []string
+	for _, word := range words {
+		if matchesPrefix(p.normalize(word), prefix, opts) {
+			filtered = append(filtered, word)
+		}
+	}
+	return filtered
+}
+
+// matchesPrefix reports whether a normalized word is indexed under a key that
+// starts with the already-normalized prefix
+func matchesPrefix(key, prefix string, opts Options) bool {
+	for _, indexed := range tokenKeys(key, opts) {
+		if strings.HasPrefix(indexed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenized reports whether words are indexed from every token
+func (o Options) tokenized() bool {
+	return o.TokenizeOnWhitespace || o.TokenDelimiters != ""
+}
+
+// shortQuery reports whether the normalized key is shorter than MinQueryLength
+func (o Options) shortQuery(key string) bool {
+	return o.MinQueryLength > 0 && utf8.RuneCountInString(key) < o.MinQueryLength
+}
+
+// tokenKeys returns the keys a normalized word is indexed under: the word itself
+// and, when tokenizing, its remainder from the start of every later token that
+// is not a stop word
+func tokenKeys(key string, opts Options) []string {
+	if key == "" {
+		return nil
+	}
+	keys := []string{key}
+	if !opts.tokenized() {
+		return keys
+	}
+	isDelimiter := func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(opts.TokenDelimiters, r)
+	}
+	isStopWord := func(token string) bool {
+		return slices.ContainsFunc(opts.StopWords, func(stop string) bool {
+			return strings.EqualFold(stop, token)
+		})
+	}
+	prevDelimiter := false
+	for i, r := range key {
+		delimiter := isDelimiter(r)
+		if i > 0 && prevDelimiter && !delimiter {
+			rest := key[i:]
+			token := rest
+			if end := strings.IndexFunc(rest, isDelimiter); end >= 0 {
+				token = rest[:end]
+			}
+			if !isStopWord(token) {
+				keys = append(keys, rest)
+			}
+		}
+		prevDelimiter = delimiter
+	}
+	return keys
+}
+
+// hasPrefix reports whether an already-normalized prefix is stored in the tree
+func (sst *StaticSearchTree) hasPrefix(prefix string) bool {
+	if sst.radix == nil {
+		_, exists := sst.lookup(prefix)
+		return exists
+	}
+	return prefix != "" && sst.radix.find(prefix) != nil
+}
+
+// Search performs a prefix search and returns all matching words, or only the
+// first DefaultLimit of them if that option is set
+func (sst *StaticSearchTree) Search(query string) []string {
+	if sst == nil {
+		return []string{}
+	}
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.defaultLimit(sst.search(query))
+}
+
+// defaultLimit truncates matches to DefaultLimit when that option is set
+func (sst *StaticSearchTree) defaultLimit(matches []string) []string {
+	if sst.opts.DefaultLimit > 0 {
+		return truncate(matches, sst.opts.DefaultLimit)
+	}
+	return matches
+}
+
+// search implements Search in the configured MatchMode; the caller must hold the lock
+func (sst *StaticSearchTree) search(query string) []string {
+	key := sst.normalize(query)
+	switch sst.opts.MatchMode {
+	case MatchExact:
+		if word, found := sst.exactWord(query); found && !sst.opts.shortQuery(key) {
+			return []string{word}
+		}
+		return []string{}
+	case MatchContains:
+		if sst.opts.shortQuery(key) {
+			return []string{}
+		}
+		return sst.cached("substring", key, func() []string {
+			return sst.searchSubstring(query)
+		})
+	}
+	return sst.searchKey(key)
+}
+
+// SearchIter yields the matches of query lazily, in the same order as Search, so
+// callers that stop early do not pay for the full result slice. The matches are
+// captured when iteration starts; later changes to the tree are not observed.
+func (sst *StaticSearchTree) SearchIter(query string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		sst.mu.RLock()
+		key := sst.normalize(query)
+		var matches []string
+		if sst.opts.MatchMode != MatchPrefix {
+			matches = sst.search(query)
+		} else if sst.opts.WhitespaceInsensitiveDedup || (sst.opts.ClickOrdering && len(sst.clicks) > 0) {
+			// Reordering and collapsing need the whole list anyway
+			matches = sst.searchKey(key)
+		} else if !sst.opts.shortQuery(key) {
+			// Prefix map lists are copy-on-write, so the shared slice stays valid
+			matches, _ = sst.lookup(key)
+		}
+		matches = sst.defaultLimit(matches)
+		sst.mu.RUnlock()
+		for _, word := range matches {
+			if !yield(word) {
+				return
+			}
+		}
+	}
+}
+
+// searchKey returns a copy of the matches of an already-normalized prefix
+func (sst *StaticSearchTree) searchKey(key string) []string {
+	if sst.opts.shortQuery(key) {
+		return []string{}
+	}
+	if matches, exists := sst.lookup(key); exists {
+		// Return a copy to prevent external modification
+		result := make([]string, len(matches))
+		copy(result, matches)
+		return sst.rank(result)
+	}
+	return []string{}
+}
+
+// rank applies the configured variant collapsing and click ordering to a sorted
+// match list owned by the caller
+func (sst *StaticSearchTree) rank(result []string) []string {
+	if sst.opts.WhitespaceInsensitiveDedup {
+		result = collapseSeparatorVariants(result)
+	}
+	if sst.opts.ClickOrdering && len(sst.clicks) > 0 {
+		sort.SliceStable(result, func(i, j int) bool {
+			return sst.clicks[result[i]] > sst.clicks[result[j]]
+		})
+	}
+	return result
+}
+
+// contextCheckInterval is the number of collected matches or scanned words between
+// checks of the context in SearchContext and FuzzySearchContext
+const contextCheckInterval = 1024
+
+// SearchFirst returns the first word Search would return for query without
+// copying the match list, e.g. to preview a completion. It returns "", false for
+// the empty query and when nothing matches.
+func (sst *StaticSearchTree) SearchFirst(query string) (string, bool) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	key := sst.normalize(query)
+	if key == "" || sst.opts.shortQuery(key) {
+		return "", false
+	}
+	if sst.opts.MatchMode != MatchPrefix || sst.opts.WhitespaceInsensitiveDedup ||
+		(sst.opts.ClickOrdering && len(sst.clicks) > 0) {
+		// The mode or ranking may put any word at the front
+		if matches := sst.search(query); len(matches) > 0 {
+			return matches[0], true
+		}
+		return "", false
+	}
+	if sst.radix == nil {
+		if matches, _ := sst.lookup(key); len(matches) > 0 {
+			return matches[0], true
+		}
+		return "", false
+	}
+	
+	// The trie is ordered by normalized key, so the first word in Search order
+	// may sit anywhere below the node
+	node := sst.radix.find(key)
+	if node == nil {
+		return "", false
+	}
+	var first string
+	found := false
+	node.walk(func(word string) bool {
+		if !found || sst.before(word, first) {
+			first, found = word, true
+		}
+		return true
+	})
+	return first, found
+}
+
+// before reports whether a precedes b in the order produced by sortMatches
+func (sst *StaticSearchTree) before(a, b string) bool {
+	if sst.opts.PreserveInsertionOrder {
+		return sst.insertion[a] < sst.insertion[b]
+	}
+	return a < b
+}
+
+// SearchContext performs a search like Search that can be abandoned through ctx,
+// returning ctx.Err() if the context is done. The prefix map backend answers
+// prefix and exact queries with a single lookup, so the context is only checked
+// up front; the radix backend also checks it periodically while collecting
+// prefix matches, and MatchContains while scanning the words. MatchContains
+// results are not cached here.
+func (sst *StaticSearchTree) SearchContext(ctx context.Context, query string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	key := sst.normalize(query)
+	if sst.opts.MatchMode == MatchContains && !sst.opts.shortQuery(key) {
+		return sst.searchSubstringContext(ctx, query)
+	}
+	if sst.radix == nil || sst.opts.shortQuery(key) || sst.opts.MatchMode != MatchPrefix {
+		return sst.search(query), nil
+	}
+	
+	var node *radixNode
+	if key != "" {
+		node = sst.radix.find(key)
+	}
+	if node == nil {
+		return []string{}, nil
+	}
+	var result []string
+	var err error
+	node.walk(func(word string) bool {
+		if len(result)%contextCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		result = append(result, word)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sst.sortMatches(result)
+	return sst.rank(slices.Compact(result)), nil
+}
+
+// SearchWithBucket searches like Search but, when the query has overtyped past
+// every indexed word, falls back to the deepest matching prefix of the query. It
+// also returns the normalized prefix that served the matches, which is empty when
+// not even the first character matches. It always matches by prefix, whatever
+// the MatchMode.
+func (sst *StaticSearchTree) SearchWithBucket(query string) (matches []string, bucket string) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	bucket = sst.deepestPrefix(sst.normalize(query))
+	return sst.searchKey(bucket), bucket
+}
+
+// LongestMatchingPrefix returns the longest stored prefix that the query itself
+// begins with, which is the reverse direction of Search: for a tree holding "ap"
+// the query "apple" yields "ap". The returned prefix is normalized.
+func (sst *StaticSearchTree) LongestMatchingPrefix(query string) (string, bool) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	prefix := sst.deepestPrefix(sst.normalize(query))
+	return prefix, prefix != ""
+}
+
+// deepestPrefix shortens an already-normalized key one character at a time until
+// it is a stored prefix, returning the empty string if none is
+func (sst *StaticSearchTree) deepestPrefix(key string) string {
+	for key != "" && !sst.hasPrefix(key) {
+		_, size := utf8.DecodeLastRuneInString(key)
+		key = key[:len(key)-size]
+	}
+	return key
+}
+
+// CountMatches returns the number of words Search would return for query before
+// DefaultLimit. Prefix matching counts without building or copying the result
+// slice; the other match modes count the result of the search.
+func (sst *StaticSearchTree) CountMatches(query string) int {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.queryCount(query)
+}
+
+// queryCount implements CountMatches in the configured MatchMode; the caller must
+// hold the lock
+func (sst *StaticSearchTree) queryCount(query string) int {
+	if sst.opts.MatchMode != MatchPrefix {
+		return len(sst.search(query))
+	}
+	key := sst.normalize(query)
+	if sst.opts.shortQuery(key) {
+		return 0
+	}
+	return sst.countMatches(key)
+}
+
+// countMatches returns the number of words matching an already-normalized prefix
+func (sst *StaticSearchTree) countMatches(prefix string) int {
+	if sst.opts.WhitespaceInsensitiveDedup {
+		// Collapsing variants needs the words themselves
+		matches, _ := sst.lookup(prefix)
+		return len(collapseSeparatorVariants(append([]string(nil), matches...)))
+	}
+	if sst.radix != nil {
+		if prefix == "" {
+			return 0
+		}
+		if sst.opts.tokenized() {
+			matches, _ := sst.lookup(prefix)
+			return len(matches)
+		}
+		if node := sst.radix.find(prefix); node != nil {
+			return node.wordCount()
+		}
+		return 0
+	}
+	matches, _ := sst.lookup(prefix)
+	return len(matches)
+}
+
+// ErrAmbiguousPrefix is returned by ResolveUnique when a query matches more than
+// one word
+var ErrAmbiguousPrefix = errors.New("ambiguous prefix")
+
+// ErrNoMatch is returned by ResolveUnique when a query matches no word
+var ErrNoMatch = errors.New("no match")
+
+// IsAmbiguous reports whether query matches more than one word, so a CLI taking
+// abbreviated commands can ask the user to type more
+func (sst *StaticSearchTree) IsAmbiguous(query string) bool {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.queryCount(query) > 1
+}
+
+// ResolveUnique returns the single word matching query, git-style. A query
+// matching several words yields ErrAmbiguousPrefix and one matching none yields
+// ErrNoMatch.
+func (sst *StaticSearchTree) ResolveUnique(query string) (string, error) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	matches := sst.search(query)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%q: %w", query, ErrNoMatch)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%q matches %d words: %w", query, len(matches), ErrAmbiguousPrefix)
+	}
+}
+
+// LoadClicks replaces the click counts used by WithClickOrdering. Each line of r
+// holds a word followed by whitespace and its click count; the count is the last
+// field so words may contain spaces. Blank lines are ignored. Loading counts
+// changes the tree version, so cached results are reordered too.
+func (sst *StaticSearchTree) LoadClicks(r io.Reader) error {
+	clicks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sep := strings.LastIndexAny(line, " \t")
+		if sep < 0 {
+			return fmt.Errorf("clicks line %d: missing count in %q", lineNo, line)
+		}
+		count, err := strconv.Atoi(line[sep+1:])
+		if err != nil {
+			return fmt.Errorf("clicks line %d: %w", lineNo, err)
+		}
+		clicks[strings.TrimSpace(line[:sep])] += count
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	sst.clicks = clicks
+	
+	// Cached results were ordered by the old counts
+	sst.version++
+	return nil
+}
+
+// collapseSeparatorVariants keeps the first of each group of words that are equal
+// once separator characters are removed
+func collapseSeparatorVariants(words []string) []string {
+	seen := make(map[string]bool)
+	result := words[:0]
+	for _, word := range words {
+		key := strings.Map(func(r rune) rune {
+			if isSeparator(r) {
+				return -1
+			}
+			return r
+		}, word)
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+// isSeparator reports whether r is treated as a word separator
+func isSeparator(r rune) bool {
+	return unicode.IsSpace(r) || r == '-' || r == '_' || r == '.'
+}
+
+// SearchWithLimit performs a prefix search with a maximum number of results, which
+// takes precedence over DefaultLimit
+func (sst *StaticSearchTree) SearchWithLimit(query string, limit int) []string {
+	if sst == nil {
+		return []string{}
+	}
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return truncate(sst.search(query), limit)
+}
+
+// SearchWith performs a prefix search and orders the matches with less, leaving
+// the ordering policy to the caller. A nil less sorts the matches lexicographically.
+func (sst *StaticSearchTree) SearchWith(query string, less func(a, b string) bool) []string {
+	sst.mu.RLock()
+	matches := sst.search(query)
+	sst.mu.RUnlock()
+	if less == nil {
+		sort.Strings(matches)
+		return matches
+	}
+	sort.Slice(matches, func(i, j int) bool { return less(matches[i], matches[j]) })
+	return matches
+}
+
+// SearchPaginated returns the page of at most limit matches starting at offset,
+// together with the total number of matches. A negative offset is treated as 0
+// and an offset past the end yields an empty page.
+func (sst *StaticSearchTree) SearchPaginated(query string, offset, limit int) ([]string, int) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	matches := sst.search(query)
+	offset = min(max(offset, 0), len(matches))
+	return truncate(matches[offset:], limit), len(matches)
+}
+
+// SetWeight sets the popularity score SearchByWeight uses for word. Words without
+// a weight score 0.
+func (sst *StaticSearchTree) SetWeight(word string, weight int) {
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	if sst.weights == nil {
+		sst.weights = make(map[string]int)
+	}
+	sst.weights[word] = weight
+}
+
+// SearchWithTag performs a prefix search restricted to the words given tag when
+// the tree was built with NewStaticSearchTreeWithTags
+func (sst *StaticSearchTree) SearchWithTag(query, tag string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	matches := sst.search(query)
+	tagged := matches[:0]
+	for _, word := range matches {
+		if slices.Contains(sst.tags[word], tag) {
+			tagged = append(tagged, word)
+		}
+	}
+	return tagged
+}
+
+// SearchByWeight performs a prefix search and returns at most limit matches,
+// highest weight first and alphabetically among equal weights
+func (sst *StaticSearchTree) SearchByWeight(query string, limit int) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	matches := sst.search(query)
+	sort.Slice(matches, func(i, j int) bool {
+		wi, wj := sst.weights[matches[i]], sst.weights[matches[j]]
+		if wi != wj {
+			return wi > wj
+		}
+		return matches[i] < matches[j]
+	})
+	return truncate(matches, limit)
+}
+
+// ScoredResult is a match of SearchWithScore together with its relevance
+type ScoredResult struct {
+	Word  string
+	Score float64
+}
+
+// SearchWithScore performs a search like Search and scores each match by
+//
+//	coverage × (1 + ln(1 + weight))
+//
+// where coverage is the length of the normalized query divided by the length of
+// the normalized word, in characters, and weight is the word's SetWeight score,
+// with negative weights counted as 0. A fully typed word scores 1 before
+// weighting, so short words the query nearly covers rank above long words it
+// barely starts. Results are sorted by descending score, in Search order on ties.
+func (sst *StaticSearchTree) SearchWithScore(query string) []ScoredResult {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	key := sst.normalize(query)
+	typed := float64(utf8.RuneCountInString(key))
+	matches := sst.search(query)
+	results := make([]ScoredResult, len(matches))
+	for i, word := range matches {
+		coverage := typed / float64(max(utf8.RuneCountInString(sst.normalize(word)), 1))
+		weight := float64(max(sst.weights[word], 0))
+		results[i] = ScoredResult{Word: word, Score: coverage * (1 + math.Log1p(weight))}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results
+}
+
+// SearchRankedByLength performs a prefix search and returns at most limit matches,
+// shortest words first with ties broken lexicographically
+func (sst *StaticSearchTree) SearchRankedByLength(query string, limit int) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	matches := sst.search(query)
+	sort.SliceStable(matches, func(i, j int) bool {
+		li, lj := utf8.RuneCountInString(matches[i]), utf8.RuneCountInString(matches[j])
+		if li != lj {
+			return li < lj
+		}
+		return matches[i] < matches[j]
+	})
+	return truncate(matches, limit)
+}
+
+// NearestByLength performs a prefix search and returns up to k matches whose length
+// in runes is closest to targetLen, closest first with ties in Search order
+func (sst *StaticSearchTree) NearestByLength(query string, targetLen, k int) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	matches := sst.search(query)
+	distance := func(word string) int {
+		d := utf8.RuneCountInString(word) - targetLen
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return distance(matches[i]) < distance(matches[j])
+	})
+	return truncate(matches, k)
+}
+
+// truncate returns at most limit leading elements of matches
+func truncate(matches []string, limit int) []string {
+	if len(matches) <= limit {
+		return matches
+	}
+	return matches[:max(limit, 0)]
+}
+
+// SearchHighlighted performs a prefix search and wraps the part of each match that
+// the query matched in open and close, e.g. "<b>app</b>le" for the query "app".
+// The original characters of the word are wrapped, whatever their case. It always
+// matches by prefix, whatever the MatchMode.
+func (sst *StaticSearchTree) SearchHighlighted(query, open, close string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	key := sst.normalize(query)
+	matches := sst.searchKey(key)
+	for i, word := range matches {
+		end := sst.matchedLength(word, key)
+		matches[i] = open + word[:end] + close + word[end:]
+	}
+	return matches
+}
+
+// GetCompletions returns, for each match of query in Search order, the rest of
+// the word after the part the query matched, e.g. "le" for "apple" and the query
+// "app", or "" when the query is the whole word. The original casing is kept. It
+// always matches by prefix, whatever the MatchMode.
+func (sst *StaticSearchTree) GetCompletions(query string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	key := sst.normalize(query)
+	matches := sst.searchKey(key)
+	for i, word := range matches {
+		matches[i] = word[sst.matchedLength(word, key):]
+	}
+	return matches
+}
+
+// SearchCompletions performs a search like Search but leaves out words equal to
+// the query after normalization, so once a complete word has been typed only
+// longer completions are suggested: with "app" and "apple" indexed, "app" yields
+// "apple"
+func (sst *StaticSearchTree) SearchCompletions(query string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	key := sst.normalize(query)
+	matches := sst.search(query)
+	completions := matches[:0]
+	for _, word := range matches {
+		if sst.normalize(word) != key {
+			completions = append(completions, word)
+		}
+	}
+	return completions
+}
+
+// matchedLength returns the length in bytes of the shortest leading part of word
+// whose normalized form starts with key, ending on a rune boundary
+func (sst *StaticSearchTree) matchedLength(word, key string) int {
+	for end := range word {
+		if end > 0 && strings.HasPrefix(sst.normalize(word[:end]), key) {
+			return end
+		}
+	}
+	return len(word)
+}
+
+// SearchCasePreferred performs a prefix search and returns the words that start
+// with query in its exact casing first, followed by the remaining case-insensitive
+// matches. Both groups keep the order of Search.
+func (sst *StaticSearchTree) SearchCasePreferred(query string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	matches := sst.search(query)
+	result := make([]string, 0, len(matches))
+	var rest []string
+	for _, word := range matches {
+		if strings.HasPrefix(word, query) {
+			result = append(result, word)
+		} else {
+			rest = append(rest, word)
+		}
+	}
+	return append(result, rest...)
+}
+
+// SearchUniqueNormalized performs a prefix search and returns at most one match per
+// lowercased NFC form, so case and composition variants merged from several
+// sources, such as "Apple" and "APPLE", appear once. The lexicographically
+// smallest variant represents each form, at the position of its first variant
+// in Search order.
+func (sst *StaticSearchTree) SearchUniqueNormalized(query string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	result := []string{}
+	positions := make(map[string]int)
+	for _, word := range sst.search(query) {
+		form := strings.ToLower(norm.NFC.String(word))
+		if i, seen := positions[form]; seen {
+			result[i] = min(result[i], word)
+			continue
+		}
+		positions[form] = len(result)
+		result = append(result, word)
+	}
+	return result
+}
+
+// SearchChanContext streams the matches of query on the returned channel. The
+// channel is closed once every match has been sent or as soon as ctx is cancelled.
+func (sst *StaticSearchTree) SearchChanContext(ctx context.Context, query string) <-chan string {
+	out := make(chan string)
+	sst.mu.RLock()
+	matches := sst.search(query)
+	sst.mu.RUnlock()
+	go func() {
+		defer close(out)
+		for _, word := range matches {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case out <- word:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// SearchSortedStream calls fn for each match of query in order of the normalized
+// words, breaking ties by the words themselves, and stops as soon as fn returns
+// false. The radix backend yields this order directly from a depth-first walk of
+// the trie; the prefix map backend, and tokenized trees, sort a copy of the match
+// list first. It always matches by prefix, whatever the MatchMode. The read
+// lock is held while fn runs, so fn must not modify the tree.
+func (sst *StaticSearchTree) SearchSortedStream(query string, fn func(string) bool) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	key := sst.normalize(query)
+	if sst.opts.shortQuery(key) {
+		return
+	}
+	if sst.radix != nil && !sst.opts.tokenized() {
+		if key == "" {
+			return
+		}
+		if node := sst.radix.find(key); node != nil {
+			node.walk(fn)
+		}
+		return
+	}
+	stored, _ := sst.lookup(key)
+	matches := append([]string(nil), stored...)
+	sort.SliceStable(matches, func(i, j int) bool {
+		return sst.normalize(matches[i]) < sst.normalize(matches[j])
+	})
+	for _, word := range matches {
+		if !fn(word) {
+			return
+		}
+	}
+}
+
+// SearchShuffled performs a prefix search and returns the matches in an order
+// shuffled by a PRNG seeded with seed, so the same seed always yields the same order
+func (sst *StaticSearchTree) SearchShuffled(query string, seed int64) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	matches := sst.search(query)
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(matches), func(i, j int) {
+		matches[i], matches[j] = matches[j], matches[i]
+	})
+	return matches
+}
+
+// PrefixDifference returns the matches of prefix a that are not matches of prefix b
+func (sst *StaticSearchTree) PrefixDifference(a, b string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	exclude := make(map[string]bool)
+	for _, word := range sst.search(b) {
+		exclude[word] = true
+	}
+	result := []string{}
+	for _, word := range sst.search(a) {
+		if !exclude[word] {
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+// SearchPrefixRange returns the indexed words w with lo <= w < hi, compared after
+// normalization, in Search order. For example the range "car" to "cat" holds
+// "car" and "cartoon" but not "cat". An empty hi leaves the range unbounded above.
+func (sst *StaticSearchTree) SearchPrefixRange(lo, hi string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	lo, hi = sst.normalize(lo), sst.normalize(hi)
+	words := sst.indexedWords()
+	keys := make([]string, len(words))
+	for i, word := range words {
+		keys[i] = sst.normalize(word)
+	}
+	sort.Sort(keyedWords{keys, words})
+	
+	start := sort.SearchStrings(keys, lo)
+	end := len(keys)
+	if hi != "" {
+		end = max(sort.SearchStrings(keys, hi), start)
+	}
+	result := append([]string{}, words[start:end]...)
+	sst.sortMatches(result)
+	return result
+}
+
+// keyedWords sorts words by their normalized keys, keeping both slices aligned
+type keyedWords struct {
+	keys  []string
+	words []string
+}
+
+func (k keyedWords) Len() int { return len(k.keys) }
+func (k keyedWords) Less(i, j int) bool {
+	if k.keys[i] != k.keys[j] {
+		return k.keys[i] < k.keys[j]
+	}
+	return k.words[i] < k.words[j]
+}
+func (k keyedWords) Swap(i, j int) {
+	k.keys[i], k.keys[j] = k.keys[j], k.keys[i]
+	k.words[i], k.words[j] = k.words[j], k.words[i]
+}
+
+// SearchAny returns the sorted union of the matches of every query, without
+// duplicates
+func (sst *StaticSearchTree) SearchAny(queries ...string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	result := []string{}
+	for _, query := range queries {
+		result = mergeDeduplicate(result, sst.search(query))
+	}
+	sort.Strings(result)
+	return result
+}
+
+// SearchBatch answers many queries under a single read lock, returning results
+// aligned with queries. Queries that normalize alike are computed once; each
+// result is still a separate slice the caller may modify.
+func (sst *StaticSearchTree) SearchBatch(queries []string) [][]string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	results := make([][]string, len(queries))
+	computed := make(map[string][]string)
+	for i, query := range queries {
+		key := sst.normalize(query)
+		if sst.opts.MatchMode == MatchExact {
+			// The exact match prefers the query's own casing
+			key = query
+		}
+		if matches, ok := computed[key]; ok {
+			results[i] = append([]string{}, matches...)
+			continue
+		}
+		results[i] = sst.defaultLimit(sst.search(query))
+		computed[key] = results[i]
+	}
+	return results
+}
+
+// SearchAnyTagged maps each query to its own matches under a single read lock,
+// so results can be grouped by the prefix that produced them. A word matching
+// several queries is listed under each.
+func (sst *StaticSearchTree) SearchAnyTagged(queries ...string) map[string][]string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	tagged := make(map[string][]string, len(queries))
+	for _, query := range queries {
+		if _, done := tagged[query]; !done {
+			tagged[query] = sst.search(query)
+		}
+	}
+	return tagged
+}
+
+// SearchAll returns the matches of prefix that also contain every one of
+// substrings, compared after normalization. For example SearchAll("report_",
+// "q3", "final") finds the final Q3 reports.
+func (sst *StaticSearchTree) SearchAll(prefix string, substrings ...string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	keys := make([]string, len(substrings))
+	for i, substring := range substrings {
+		keys[i] = sst.normalize(substring)
+	}
+	result := []string{}
+	for _, word := range sst.search(prefix) {
+		normalized := sst.normalize(word)
+		matchesAll := true
+		for _, key := range keys {
+			if !strings.Contains(normalized, key) {
+				matchesAll = false
+				break
+			}
+		}
+		if matchesAll {
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+// CanonicalizeQueries maps each query to the indexed word it most likely refers to:
+// the word equal to it after normalization if there is one, otherwise the word with
+// the smallest edit distance. Queries map to "" when the tree is empty.
+func (sst *StaticSearchTree) CanonicalizeQueries(queries []string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	words := sst.indexedWords()
+	result := make([]string, len(queries))
+	for i, query := range queries {
+		if word, ok := sst.exactWord(query); ok {
+			result[i] = word
+		} else if word, ok := sst.nearestWord(query, words); ok {
+			result[i] = word
+		}
+	}
+	return result
+}
+
+// exactWord returns the indexed word whose normalized form equals the normalized
+// query, preferring one that matches query exactly when case variants exist
+func (sst *StaticSearchTree) exactWord(query string) (string, bool) {
+	key := sst.normalize(query)
+	matches, _ := sst.lookup(key)
+	found := ""
+	for _, word := range matches {
+		if sst.normalize(word) != key {
+			continue
+		}
+		if word == query {
+			return word, true
+		}
+		if found == "" {
+			found = word
+		}
+	}
+	return found, found != ""
+}
+
+// DidYouMean suggests the indexed word closest to a query that has no prefix
+// matches. It returns false when the query does match, when the tree is empty, or
+// when the closest word needs more than two edits, or one edit per three
+// characters of a longer query, so unrelated queries get no suggestion.
+func (sst *StaticSearchTree) DidYouMean(query string) (string, bool) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	key := sst.normalize(query)
+	if key == "" || sst.hasPrefix(key) {
+		return "", false
+	}
+	best, found := sst.nearestWord(query, sst.indexedWords())
+	if !found {
+		return "", false
+	}
+	threshold := max(2, utf8.RuneCountInString(key)/3)
+	if levenshtein(key, sst.normalize(best)) > threshold {
+		return "", false
+	}
+	return best, true
+}
+
+// nearestWord returns the word with the smallest edit distance to query after
+// normalization, taking the first in order on ties
+func (sst *StaticSearchTree) nearestWord(query string, words []string) (string, bool) {
+	key := sst.normalize(query)
+	best, bestDistance := "", -1
+	for _, word := range words {
+		d := levenshtein(key, sst.normalize(word))
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = word, d
+		}
+	}
+	return best, bestDistance >= 0
+}
+
+// FuzzySearch returns the words that have a prefix within maxEdits insertions,
+// deletions or substitutions of query, so "aple" still finds "apple" with one edit.
+// With maxEdits of 0 it returns the same matches as Search, without DefaultLimit.
+//
+// Unlike Search this is not a lookup: every indexed word is compared against the
+// query, costing O(N·L·Q) for N words of average length L and a query of length Q.
+func (sst *StaticSearchTree) FuzzySearch(query string, maxEdits int) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	mode := "fuzzy/" + strconv.Itoa(maxEdits)
+	return sst.cached(mode, sst.normalize(query), func() []string {
+		return sst.fuzzySearch(query, maxEdits)
+	})
+}
+
+// FuzzySearchContext performs a search like FuzzySearch that can be abandoned
+// through ctx, checking it periodically while scanning the words and returning
+// ctx.Err() if the context is done. Its results are not cached.
+func (sst *StaticSearchTree) FuzzySearchContext(ctx context.Context, query string, maxEdits int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.fuzzySearchContext(ctx, query, maxEdits)
+}
+
+// fuzzySearch implements FuzzySearch; the caller must hold the lock
+func (sst *StaticSearchTree) fuzzySearch(query string, maxEdits int) []string {
+	result, _ := sst.fuzzySearchContext(context.Background(), query, maxEdits)
+	return result
+}
+
+// fuzzySearchContext implements FuzzySearchContext; the caller must hold the lock
+func (sst *StaticSearchTree) fuzzySearchContext(ctx context.Context, query string, maxEdits int) ([]string, error) {
+	if maxEdits == 0 {
+		return sst.search(query), nil
+	}
+	key := sst.normalize(query)
+	result := []string{}
+	if key == "" || maxEdits < 0 {
+		return result, nil
+	}
+	for i, word := range sst.indexedWords() {
+		if i%contextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if prefixEditDistance(key, sst.normalize(word)) <= maxEdits {
+			result = append(result, word)
+		}
+	}
+	return sst.rank(result), nil
+}
+
+// SearchSubstring returns the words that contain query anywhere, not only as a
+// prefix, in the same order as Search. Like FuzzySearch it scans every indexed word.
+func (sst *StaticSearchTree) SearchSubstring(query string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.cached("substring", sst.normalize(query), func() []string {
+		return sst.searchSubstring(query)
+	})
+}
+
+// searchSubstring implements SearchSubstring; the caller must hold the lock
+func (sst *StaticSearchTree) searchSubstring(query string) []string {
+	result, _ := sst.searchSubstringContext(context.Background(), query)
+	return result
+}
+
+// searchSubstringContext implements searchSubstring, checking ctx periodically
+// while scanning the words; the caller must hold the lock
+func (sst *StaticSearchTree) searchSubstringContext(ctx context.Context, query string) ([]string, error) {
+	key := sst.normalize(query)
+	result := []string{}
+	if key == "" {
+		return result, nil
+	}
+	for i, word := range sst.indexedWords() {
+		if i%contextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		if strings.Contains(sst.normalize(word), key) {
+			result = append(result, word)
+		}
+	}
+	return sst.rank(result), nil
+}
+
+// SearchRegex returns the sorted indexed words matching the regular expression
+// pattern. Matching is against the original words and is case-sensitive unless
+// the pattern uses the (?i) flag. An invalid pattern yields the compile error.
+func (sst *StaticSearchTree) SearchRegex(pattern string) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.cached("regex", pattern, func() []string {
+		result := []string{}
+		for _, word := range sst.indexedWords() {
+			if re.MatchString(word) {
+				result = append(result, word)
+			}
+		}
+		return result
+	}), nil
+}
+
+// cached returns the result of compute for the search mode and query from the
+// result cache, computing and storing it on a miss. The cache is emptied when the
+// tree has changed since it was filled. The caller must hold the read lock.
+func (sst *StaticSearchTree) cached(mode, query string, compute func() []string) []string {
+	if sst.cache == nil {
+		return compute()
+	}
+	key := mode + "\x00" + query
+	
+	sst.cacheMu.Lock()
+	if sst.cacheVersion != sst.version {
+		sst.cache.reset()
+		sst.cacheVersion = sst.version
+	}
+	result, ok := sst.cache.get(key)
+	sst.cacheMu.Unlock()
+	if ok {
+		return append([]string{}, result...)
+	}
+	
+	result = compute()
+	sst.cacheMu.Lock()
+	sst.cache.put(key, append([]string{}, result...))
+	sst.cacheMu.Unlock()
+	return result
+}
+
+// CacheStats reports the hits and misses of the result cache enabled with
+// WithResultCache
+func (sst *StaticSearchTree) CacheStats() (hits, misses uint64) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	if sst.cache == nil {
+		return 0, 0
+	}
+	sst.cacheMu.Lock()
+	defer sst.cacheMu.Unlock()
+	return sst.cache.hits, sst.cache.misses
+}
+
+// SearchSuffix returns the sorted indexed words ending with query, compared after
+// normalization. With SuffixIndex this is a lookup in the reversed index;
+// otherwise every word is scanned. An empty query matches nothing.
+func (sst *StaticSearchTree) SearchSuffix(query string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	if sst.suffixes != nil {
+		return sst.suffixes.search(query)
+	}
+	key := sst.normalize(query)
+	result := []string{}
+	if key == "" {
+		return result
+	}
+	for _, word := range sst.indexedWords() {
+		if strings.HasSuffix(sst.normalize(word), key) {
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+// prefixEditDistance returns the smallest edit distance between query and any
+// prefix of word, counted in runes
+func prefixEditDistance(query, word string) int {
+	rq := []rune(query)
+	prev := make([]int, len(rq)+1)
+	curr := make([]int, len(rq)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	best := prev[len(rq)]
+	for _, r := range word {
+		curr[0] = prev[0] + 1
+		for j := 1; j <= len(rq); j++ {
+			cost := 1
+			if rq[j-1] == r {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+		best = min(best, prev[len(rq)])
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b, counted in runes
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// PrefixExists reports whether any indexed word starts with query, whatever the
+// MatchMode
+func (sst *StaticSearchTree) PrefixExists(query string) bool {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.hasPrefix(sst.normalize(query))
+}
+
+// ExactMatch reports whether word itself was indexed, ignoring case, as opposed to
+// PrefixExists which also accepts the beginning of a longer word
+func (sst *StaticSearchTree) ExactMatch(word string) bool {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	_, found := sst.exactWord(word)
+	return found
+}
+
+// CoverageOf reports how many queries in queryLog have at least one prefix match,
+// whatever the MatchMode, out of the total number of logged queries
+func (sst *StaticSearchTree) CoverageOf(queryLog []string) (covered, total int) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	for _, query := range queryLog {
+		if sst.hasPrefix(sst.normalize(query)) {
+			covered++
+		}
+	}
+	return covered, len(queryLog)
+}
+
+// TopQueries returns the n most frequent queries in queryLog, normalized so that
+// variants such as "App" and "app" count together, which makes it a good source
+// of queries for warming caches. Ties are broken alphabetically.
+func (sst *StaticSearchTree) TopQueries(queryLog []string, n int) []string {
+	sst.mu.RLock()
+	counts := make(map[string]int)
+	for _, query := range queryLog {
+		counts[sst.normalize(query)]++
+	}
+	sst.mu.RUnlock()
+	
+	queries := make([]string, 0, len(counts))
+	for query := range counts {
+		queries = append(queries, query)
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		if counts[queries[i]] != counts[queries[j]] {
+			return counts[queries[i]] > counts[queries[j]]
+		}
+		return queries[i] < queries[j]
+	})
+	return truncate(queries, n)
+}
+
+// GetAllPrefixes returns all stored prefixes (useful for debugging)
+func (sst *StaticSearchTree) GetAllPrefixes() []string {
+	if sst == nil {
+		return nil
+	}
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.prefixes()
+}
+
+// Each calls fn with every stored prefix, in sorted order, and its matches, until
+// fn returns false. The prefix map backend passes its own match lists instead of
+// copies, so fn must treat them as read-only. The read lock is held while fn
+// runs, so fn must not modify the tree.
+func (sst *StaticSearchTree) Each(fn func(prefix string, matches []string) bool) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	for _, prefix := range sst.prefixes() {
+		matches, _ := sst.lookup(prefix)
+		if !fn(prefix, matches) {
+			return
+		}
+	}
+}
+
+// prefixes implements GetAllPrefixes; the caller must hold the lock
+func (sst *StaticSearchTree) prefixes() []string {
+	if sst.radix != nil {
+		prefixes := sst.radix.prefixes("", nil)
+		sort.Strings(prefixes)
+		return prefixes
+	}
+	var prefixes []string
+	for prefix := range sst.tree {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// Size returns the number of entries the index stores: trie nodes, not counting
+// the root, for the radix backend, and precomputed prefixes for the prefix map
+// backend.
+func (sst *StaticSearchTree) Size() int {
+	if sst == nil {
+		return 0
+	}
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	if sst.radix != nil {
+		return sst.radix.nodeCount()
+	}
+	return len(sst.tree)
+}
+
+// TreeStats summarizes the size of a tree at a point in time
+type TreeStats struct {
+	// Prefixes is the number of distinct prefixes with matches. It equals Size for
+	// the prefix map backend; the radix backend derives it from its edge labels.
+	Prefixes int
+	// Words is the number of distinct indexed words
+	Words int
+	// Version is incremented by every change to the tree
+	Version uint64
+	// LongestMatchList is the largest number of words matching a single prefix
+	LongestMatchList int
+	// LongestMatchPrefix is the prefix owning LongestMatchList, the alphabetically
+	// first one on ties
+	LongestMatchPrefix string
+	// AverageMatchList is the mean number of words matching a stored prefix
+	AverageMatchList float64
+	// EstimatedBytes approximates the memory held by the index: string and slice
+	// headers, prefix keys or edge labels, and the words themselves. Map and
+	// allocator overhead are not included.
+	EstimatedBytes int
+	// ReservedBytes is EstimatedBytes plus the unused capacity of the match lists
+	// and trie nodes, which deletions leave behind until Compact is called
+	ReservedBytes int
+}
+
+// Sizes of the headers counted by TreeStats.EstimatedBytes
+const (
+	stringHeaderBytes = int(unsafe.Sizeof(""))
+	sliceHeaderBytes  = int(unsafe.Sizeof([]string(nil)))
+	pointerBytes      = int(unsafe.Sizeof((*radixNode)(nil)))
+	radixEdgeBytes    = int(unsafe.Sizeof(radixEdge{}))
 )
 
-// StaticSearchTree represents a precomputed search tree for efficient prefix matching
-type StaticSearchTree struct {
-	tree map[string][]string
+// EstimateTreeSize reports, without building it, how many prefixes a tree built
+// from words with BackendPrefixMap would store and roughly how many bytes it
+// would hold, using the same accounting as TreeStats.EstimatedBytes. It lets
+// callers refuse or switch strategies before indexing a huge word list.
+func EstimateTreeSize(words []string) (prefixCount int, approxBytes int64) {
+	p := buildPipeline(Options{})
+	seen := make(map[string]bool, len(words))
+	var keys []string
+	for _, word := range words {
+		key := p.normalize(word)
+		if key == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+		keys = append(keys, key)
+		
+		// The word itself, plus one reference in the match list of each prefix
+		approxBytes += int64(len(word) + len(key)*stringHeaderBytes)
+	}
+	sort.Strings(keys)
+	
+	for j, key := range keys {
+		shared := 0
+		if j > 0 {
+			shared = commonPrefixLen(keys[j-1], key)
+		}
+		for length := shared + 1; length <= len(key); length++ {
+			prefixCount++
+			approxBytes += int64(stringHeaderBytes + length + sliceHeaderBytes)
+		}
+	}
+	return prefixCount, approxBytes
 }
 
-// NewStaticSearchTree creates a new static search tree from a list of words
-func NewStaticSearchTree(words []string) *StaticSearchTree {
-	sst := &StaticSearchTree{
-		tree: make(map[string][]string),
+// Stats reports the current size of the tree
+func (sst *StaticSearchTree) Stats() TreeStats {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.stats()
+}
+
+// stats implements Stats; the caller must hold the lock
+func (sst *StaticSearchTree) stats() TreeStats {
+	words := sst.indexedWords()
+	stats := TreeStats{Words: len(words), Version: sst.version}
+	for _, word := range words {
+		stats.EstimatedBytes += len(word)
 	}
-	sst.build(words)
-	return sst
+	
+	matched := 0
+	record := func(prefix string, count int) {
+		if count > stats.LongestMatchList || (count == stats.LongestMatchList && prefix < stats.LongestMatchPrefix) {
+			stats.LongestMatchList = count
+			stats.LongestMatchPrefix = prefix
+		}
+	}
+	if sst.radix != nil {
+		sst.radix.visitCounts("", func(path string, edge radixEdge, count int) {
+			if sst.opts.tokenized() {
+				// Count each word once even when several of its tokens are below
+				matches, _ := sst.lookup(path + edge.label)
+				count = len(matches)
+			}
+			stats.Prefixes += len(edge.label)
+			matched += len(edge.label) * count
+			record(path+edge.label[:1], count)
+			stats.EstimatedBytes += stringHeaderBytes + len(edge.label) + pointerBytes +
+				2*sliceHeaderBytes + len(edge.node.words)*stringHeaderBytes
+		})
+		stats.EstimatedBytes += 2 * sliceHeaderBytes
+		stats.ReservedBytes = stats.EstimatedBytes + sst.radix.spareBytes()
+	} else {
+		spare := 0
+		for prefix, matches := range sst.tree {
+			stats.Prefixes++
+			matched += len(matches)
+			record(prefix, len(matches))
+			stats.EstimatedBytes += stringHeaderBytes + len(prefix) + sliceHeaderBytes +
+				len(matches)*stringHeaderBytes
+			spare += (cap(matches) - len(matches)) * stringHeaderBytes
+		}
+		stats.ReservedBytes = stats.EstimatedBytes + spare
+	}
+	if stats.Prefixes > 0 {
+		stats.AverageMatchList = float64(matched) / float64(stats.Prefixes)
+	}
+	return stats
 }
 
-// build constructs the static search tree by precomputing all prefix combinations
-func (sst *StaticSearchTree) build(words []string) {
-	// Sort words to ensure consistent ordering
-	sort.Strings(words)
+// ErrCorruptTree is returned by Validate when an internal invariant does not hold
+var ErrCorruptTree = errors.New("corrupt tree")
+
+// Validate checks the internal invariants of the index: every word of a match
+// list starts with its prefix after normalization, no match list holds a word
+// twice, and no prefix is stored without matches. For the radix backend the same
+// is checked of the words stored at each node. It returns the first violation
+// it finds, wrapping ErrCorruptTree, and is meant for catching bugs in mutations.
+func (sst *StaticSearchTree) Validate() error {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.validate()
+}
+
+// validate implements Validate; the caller must hold the lock
+func (sst *StaticSearchTree) validate() error {
+	if sst.suffixes != nil {
+		if err := sst.suffixes.validate(); err != nil {
+			return fmt.Errorf("suffix index: %w", err)
+		}
+	}
+	if sst.radix != nil {
+		return sst.validateNode(sst.radix, "")
+	}
 	
-	// For each word, generate all possible prefixes and their matching results
-	for _, word := range words {
-		// Generate all prefixes of the word
-		for i := 1; i <= len(word); i++ {
-			prefix := strings.ToLower(word[:i])
-			
-			// Find all words that match this prefix
-			var matches []string
-			for _, candidate := range words {
-				if strings.HasPrefix(strings.ToLower(candidate), prefix) {
-					matches = append(matches, candidate)
+	prefixes := make([]string, 0, len(sst.tree))
+	for prefix := range sst.tree {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		matches := sst.tree[prefix]
+		if len(matches) == 0 {
+			return fmt.Errorf("prefix %q has no matches: %w", prefix, ErrCorruptTree)
+		}
+		seen := make(map[string]bool, len(matches))
+		for _, word := range matches {
+			if seen[word] {
+				return fmt.Errorf("prefix %q lists %q twice: %w", prefix, word, ErrCorruptTree)
+			}
+			seen[word] = true
+			if !matchesPrefix(sst.normalize(word), prefix, sst.opts) {
+				return fmt.Errorf("prefix %q lists %q, which does not start with it: %w", prefix, word, ErrCorruptTree)
+			}
+		}
+	}
+	return nil
+}
+
+// validateNode checks the subtree of the radix backend rooted at n, which path leads to
+func (sst *StaticSearchTree) validateNode(n *radixNode, path string) error {
+	if n != sst.radix && len(n.words) == 0 && len(n.edges) == 0 {
+		return fmt.Errorf("node %q has no words: %w", path, ErrCorruptTree)
+	}
+	for i, word := range n.words {
+		if i > 0 && n.words[i-1] == word {
+			return fmt.Errorf("node %q holds %q twice: %w", path, word, ErrCorruptTree)
+		}
+		if !slices.Contains(tokenKeys(sst.normalize(word), sst.opts), path) {
+			return fmt.Errorf("node %q holds %q, which is not indexed under it: %w", path, word, ErrCorruptTree)
+		}
+	}
+	for _, edge := range n.edges {
+		if err := sst.validateNode(edge.node, path+edge.label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchStats emits the current Stats on the returned channel every interval until
+// the returned stop function is called, after which the channel is closed
+func (sst *StaticSearchTree) WatchStats(interval time.Duration) (<-chan TreeStats, func()) {
+	out := make(chan TreeStats)
+	done := make(chan struct{})
+	var once sync.Once
+	stop := func() { once.Do(func() { close(done) }) }
+	
+	go func() {
+		defer close(out)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				select {
+				case out <- sst.Stats():
+				case <-done:
+					return
 				}
+			case <-done:
+				return
 			}
-			
-			// Store the matches for this prefix (avoiding duplicates)
-			if existing, exists := sst.tree[prefix]; exists {
-				// Merge and deduplicate
-				merged := mergeDeduplicate(existing, matches)
-				sst.tree[prefix] = merged
-			} else {
-				sst.tree[prefix] = matches
+		}
+	}()
+	return out, stop
+}
+
+// MinimalCoveringPrefixes returns a small set of prefixes such that every indexed
+// word matches at least one of them. Prefixes are chosen greedily: a prefix is
+// kept once it matches at most maxPerPrefix words, otherwise it is split into its
+// one-character extensions. A prefix that is itself a complete word cannot be
+// split without losing that word, so it is kept even if it exceeds the limit.
+func (sst *StaticSearchTree) MinimalCoveringPrefixes(maxPerPrefix int) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	var result []string
+	
+	var cover func(prefix string, depth int)
+	cover = func(prefix string, depth int) {
+		matches, _ := sst.lookup(prefix)
+		if len(matches) <= maxPerPrefix {
+			result = append(result, prefix)
+			return
+		}
+		for _, word := range matches {
+			if utf8.RuneCountInString(sst.normalize(word)) == depth {
+				result = append(result, prefix)
+				return
 			}
 		}
+		for _, child := range sst.childPrefixes(matches, depth+1) {
+			cover(child, depth+1)
+		}
+	}
+	
+	for _, first := range sst.childPrefixes(sst.indexedWords(), 1) {
+		cover(first, 1)
 	}
+	return result
 }
 
-// mergeDeduplicate merges two slices and removes duplicates
-func mergeDeduplicate(slice1, slice2 []string) []string {
+// childPrefixes returns the sorted distinct normalized prefixes of length depth
+// (in runes) among the given words
+func (sst *StaticSearchTree) childPrefixes(words []string, depth int) []string {
 	seen := make(map[string]bool)
-	var result []string
+	var prefixes []string
+	for _, word := range words {
+		prefix := runePrefix(sst.normalize(word), depth)
+		if prefix != "" && !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// runePrefix returns the first n runes of s, or "" if s is shorter
+func runePrefix(s string, n int) string {
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	if count == n {
+		return s
+	}
+	return ""
+}
+
+// Words returns the sorted set of distinct words stored in the tree
+func (sst *StaticSearchTree) Words() []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.indexedWords()
+}
+
+// Diff compares the distinct words of two trees, such as an index before and
+// after a rebuild from a new data snapshot, and returns the sorted words only
+// after holds and the sorted words only before holds
+func Diff(before, after *StaticSearchTree) (added, removed []string) {
+	oldWords := before.Words()
+	newWords := after.Words()
+	added, removed = []string{}, []string{}
+	i, j := 0, 0
+	for i < len(oldWords) || j < len(newWords) {
+		switch {
+		case j == len(newWords) || (i < len(oldWords) && oldWords[i] < newWords[j]):
+			removed = append(removed, oldWords[i])
+			i++
+		case i == len(oldWords) || newWords[j] < oldWords[i]:
+			added = append(added, newWords[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
+
+// WriteWords writes the sorted set of distinct indexed words to w, one per line
+func (sst *StaticSearchTree) WriteWords(w io.Writer) error {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	bw := bufio.NewWriter(w)
+	for _, word := range sst.indexedWords() {
+		if _, err := bw.WriteString(word); err != nil {
+			return err
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// indexedWords returns the sorted set of distinct words stored in the tree
+func (sst *StaticSearchTree) indexedWords() []string {
+	if sst.radix != nil {
+		words := sst.radix.collect(nil)
+		sort.Strings(words)
+		return slices.Compact(words)
+	}
+	words := make([]string, 0, len(sst.words))
+	for word := range sst.words {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// wordSet returns the set of distinct words listed in a prefix map
+func wordSet(tree map[string][]string) map[string]struct{} {
+	words := make(map[string]struct{})
+	for _, matches := range tree {
+		for _, word := range matches {
+			words[word] = struct{}{}
+		}
+	}
+	return words
+}
+
+// UniquePrefixes maps each indexed word to the shortest prefix that matches no other
+// word, which is useful for command abbreviations. Words without such a prefix,
+// such as a word that is a prefix of another, are omitted.
+func (sst *StaticSearchTree) UniquePrefixes() map[string]string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	unique := make(map[string]string)
+	for _, word := range sst.indexedWords() {
+		if prefix, found := sst.uniquePrefix(sst.normalize(word)); found {
+			unique[word] = prefix
+		}
+	}
+	return unique
+}
+
+// uniquePrefix returns the shortest non-empty prefix of the normalized key that
+// matches exactly one word
+func (sst *StaticSearchTree) uniquePrefix(key string) (string, bool) {
+	for i := range key {
+		if i == 0 {
+			continue
+		}
+		if matches, _ := sst.lookup(key[:i]); len(matches) == 1 {
+			return key[:i], true
+		}
+	}
+	if matches, _ := sst.lookup(key); len(matches) == 1 {
+		return key, true
+	}
+	return "", false
+}
+
+// UniquenessDepth returns the number of characters of word that must be typed
+// before a search matches only that word, or -1 if word is not indexed or never
+// becomes unambiguous
+func (sst *StaticSearchTree) UniquenessDepth(word string) int {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.uniquenessDepth(word)
+}
+
+// uniquenessDepth implements UniquenessDepth; the caller must hold the lock
+func (sst *StaticSearchTree) uniquenessDepth(word string) int {
+	if _, indexed := sst.exactWord(word); !indexed {
+		return -1
+	}
+	prefix, found := sst.uniquePrefix(sst.normalize(word))
+	if !found {
+		return -1
+	}
+	return utf8.RuneCountInString(prefix)
+}
+
+// KeystrokeSavings estimates the average number of characters saved per query in
+// queryLog if each query were completed as soon as it becomes unambiguous.
+// Queries that are not indexed words, or that never become unambiguous, save
+// nothing but still count towards the average.
+func (sst *StaticSearchTree) KeystrokeSavings(queryLog []string) float64 {
+	if len(queryLog) == 0 {
+		return 0
+	}
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	saved := 0
+	for _, query := range queryLog {
+		if depth := sst.uniquenessDepth(query); depth >= 0 {
+			saved += utf8.RuneCountInString(sst.normalize(query)) - depth
+		}
+	}
+	return float64(saved) / float64(len(queryLog))
+}
+
+// PrefixEfficiency scores every stored prefix by how much it narrows the indexed
+// words per character typed, measured as log2(words/matches) divided by the
+// prefix length. Prefixes that cut the set down sharply score high; prefixes
+// that barely filter score close to zero.
+func (sst *StaticSearchTree) PrefixEfficiency() map[string]float64 {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	total := float64(len(sst.indexedWords()))
+	scores := make(map[string]float64)
+	for _, prefix := range sst.prefixes() {
+		length := utf8.RuneCountInString(prefix)
+		matches := sst.countMatches(prefix)
+		if length == 0 || matches == 0 {
+			continue
+		}
+		scores[prefix] = math.Log2(total/float64(matches)) / float64(length)
+	}
+	return scores
+}
+
+// PrefixFrequencies maps every stored prefix to the number of words it matches
+func (sst *StaticSearchTree) PrefixFrequencies() map[string]int {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return sst.prefixFrequencies()
+}
+
+// prefixFrequencies implements PrefixFrequencies; the caller must hold the lock
+func (sst *StaticSearchTree) prefixFrequencies() map[string]int {
+	prefixes := sst.prefixes()
+	counts := make(map[string]int, len(prefixes))
+	for _, prefix := range prefixes {
+		counts[prefix] = sst.countMatches(prefix)
+	}
+	return counts
+}
+
+// TopPrefixes returns the n stored prefixes with the most matches, which are the
+// queries most worth tuning in a UI. Ties are broken lexicographically.
+func (sst *StaticSearchTree) TopPrefixes(n int) []string {
+	sst.mu.RLock()
+	counts := sst.prefixFrequencies()
+	sst.mu.RUnlock()
 	
-	// Add all items from both slices
-	for _, item := range slice1 {
-		if !seen[item] {
-			seen[item] = true
-			result = append(result, item)
+	prefixes := make([]string, 0, len(counts))
+	for prefix := range counts {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		if counts[prefixes[i]] != counts[prefixes[j]] {
+			return counts[prefixes[i]] > counts[prefixes[j]]
+		}
+		return prefixes[i] < prefixes[j]
+	})
+	return truncate(prefixes, n)
+}
+
+// FirstCharacters returns the sorted distinct first characters of the indexed
+// words, after normalization
+func (sst *StaticSearchTree) FirstCharacters() []rune {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	seen := make(map[rune]bool)
+	chars := []rune{}
+	for _, word := range sst.indexedWords() {
+		r, size := utf8.DecodeRuneInString(sst.normalize(word))
+		if size > 0 && !seen[r] {
+			seen[r] = true
+			chars = append(chars, r)
+		}
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+	return chars
+}
+
+// NextChars returns the sorted distinct characters that can follow query in the
+// matching words, after normalization, e.g. 'r' and 't' after "ca" for "car",
+// "care" and "cat". It is empty when nothing matches or the query only matches
+// whole words. It always matches by prefix, whatever the MatchMode.
+func (sst *StaticSearchTree) NextChars(query string) []rune {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	key := sst.normalize(query)
+	seen := make(map[rune]bool)
+	chars := []rune{}
+	for _, word := range sst.searchKey(key) {
+		for _, indexed := range tokenKeys(sst.normalize(word), sst.opts) {
+			if !strings.HasPrefix(indexed, key) {
+				continue
+			}
+			r, size := utf8.DecodeRuneInString(indexed[len(key):])
+			if size > 0 && !seen[r] {
+				seen[r] = true
+				chars = append(chars, r)
+			}
+		}
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+	return chars
+}
+
+// AlphabeticalBuckets groups the matches of query by the first character that
+// follows the query, lowercased. A word equal to the query has no following
+// character and is grouped under the zero rune.
+func (sst *StaticSearchTree) AlphabeticalBuckets(query string) map[rune][]string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	buckets := make(map[rune][]string)
+	depth := utf8.RuneCountInString(sst.normalize(query))
+	for _, word := range sst.search(query) {
+		var next rune
+		count := 0
+		for _, r := range sst.normalize(word) {
+			if count == depth {
+				next = r
+				break
+			}
+			count++
 		}
+		buckets[next] = append(buckets[next], word)
 	}
+	return buckets
+}
+
+// treeData is the serialized form of a StaticSearchTree used by both the JSON and
+// gob encodings. The prefix map backend stores its precomputed prefixes so loading
+// skips build; the radix backend stores its word list and rebuilds the trie.
+type treeData struct {
+	Options  Options             `json:"options"`
+	Prefixes map[string][]string `json:"prefixes,omitempty"`
+	Words    []string            `json:"words,omitempty"`
 	
-	for _, item := range slice2 {
-		if !seen[item] {
-			seen[item] = true
-			result = append(result, item)
+	// Order lists the words by insertion rank when PreserveInsertionOrder is set
+	Order []string `json:"order,omitempty"`
+}
+
+// data returns the serialized form of the tree; the caller must hold the lock
+func (sst *StaticSearchTree) data() treeData {
+	data := treeData{Options: sst.opts}
+	if sst.radix != nil {
+		data.Words = sst.indexedWords()
+	} else {
+		data.Prefixes = sst.tree
+	}
+	if sst.opts.PreserveInsertionOrder {
+		data.Order = sst.indexedWords()
+		sst.sortMatches(data.Order)
+	}
+	return data
+}
+
+// restore replaces the contents of the tree with previously serialized data
+func (sst *StaticSearchTree) restore(data treeData) {
+	loaded := newTree(data.Options)
+	if data.Options.PreserveInsertionOrder {
+		loaded.rankWords(data.Order)
+	}
+	if loaded.radix != nil {
+		for _, word := range data.Words {
+			loaded.insert(word)
+		}
+	} else if data.Prefixes != nil {
+		loaded.tree = data.Prefixes
+		loaded.words = wordSet(data.Prefixes)
+		if loaded.bloom != nil {
+			loaded.rebuildBloom()
 		}
 	}
+	if loaded.suffixes != nil {
+		words := loaded.indexedWords()
+		loaded.sortMatches(words)
+		loaded.suffixes.build(words)
+	}
 	
-	return result
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	sst.tree = loaded.tree
+	sst.words = loaded.words
+	sst.radix = loaded.radix
+	sst.opts = loaded.opts
+	sst.pipeline = loaded.pipeline
+	sst.cache = loaded.cache
+	sst.suffixes = loaded.suffixes
+	sst.bloom = loaded.bloom
+	sst.insertion = loaded.insertion
+	sst.nextInsertion = loaded.nextInsertion
+	sst.version++
 }
 
-// Search performs a prefix search and returns all matching words
-func (sst *StaticSearchTree) Search(query string) []string {
-	query = strings.ToLower(query)
-	if matches, exists := sst.tree[query]; exists {
-		// Return a copy to prevent external modification
-		result := make([]string, len(matches))
-		copy(result, matches)
-		return result
+// MarshalJSON encodes the tree, including its options, as JSON
+func (sst *StaticSearchTree) MarshalJSON() ([]byte, error) {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return json.Marshal(sst.data())
+}
+
+// UnmarshalJSON replaces the contents of the tree with JSON produced by MarshalJSON
+func (sst *StaticSearchTree) UnmarshalJSON(b []byte) error {
+	var data treeData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
 	}
-	return []string{}
+	sst.restore(data)
+	return nil
 }
 
-// SearchWithLimit performs a prefix search with a maximum number of results
-func (sst *StaticSearchTree) SearchWithLimit(query string, limit int) []string {
-	matches := sst.Search(query)
-	if len(matches) <= limit {
-		return matches
+// Save writes the tree to w using encoding/gob, which is more compact and faster
+// to load than JSON
+func (sst *StaticSearchTree) Save(w io.Writer) error {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return gob.NewEncoder(w).Encode(sst.data())
+}
+
+// Load replaces the contents of the tree with data written by Save. The prefix
+// map is decoded directly, so loading is proportional to the size of the input
+// rather than to the cost of build.
+func (sst *StaticSearchTree) Load(r io.Reader) error {
+	var data treeData
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return err
 	}
-	return matches[:limit]
+	sst.restore(data)
+	return nil
 }
 
-// GetAllPrefixes returns all stored prefixes (useful for debugging)
-func (sst *StaticSearchTree) GetAllPrefixes() []string {
-	var prefixes []string
-	for prefix := range sst.tree {
-		prefixes = append(prefixes, prefix)
+// SearchSnapshot is an immutable view of a StaticSearchTree at a given version.
+// Later changes to the tree are not visible through the snapshot.
+type SearchSnapshot struct {
+	sst     *StaticSearchTree
+	version uint64
+}
+
+// Snapshot returns an immutable view of the tree's current contents. The prefix
+// map backend never modifies a stored match list in place, so only the map
+// itself is copied.
+func (sst *StaticSearchTree) Snapshot() *SearchSnapshot {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	return &SearchSnapshot{sst: sst.copyTree(), version: sst.version}
+}
+
+// copyTree returns a copy of the tree that later changes to sst do not affect;
+// the caller must hold the lock
+func (sst *StaticSearchTree) copyTree() *StaticSearchTree {
+	copied := &StaticSearchTree{
+		opts:     sst.opts,
+		pipeline: sst.pipeline,
+		clicks:   sst.clicks,
+		weights:  maps.Clone(sst.weights),
+		tags:     sst.tags,
+		version:  sst.version,
+		
+		insertion:     maps.Clone(sst.insertion),
+		nextInsertion: sst.nextInsertion,
 	}
-	sort.Strings(prefixes)
-	return prefixes
+	if sst.radix != nil {
+		// Trie nodes are updated in place, so the radix backend needs a deep copy
+		copied.radix = sst.radix.clone()
+	} else {
+		copied.tree = make(map[string][]string, len(sst.tree))
+		for prefix, matches := range sst.tree {
+			copied.tree[prefix] = matches
+		}
+		copied.words = maps.Clone(sst.words)
+		if sst.bloom != nil {
+			// Insert sets bits in place
+			copied.bloom = sst.bloom.clone()
+		}
+	}
+	if sst.suffixes != nil {
+		copied.suffixes = sst.suffixes.copyTree()
+	}
+	return copied
 }
 
-// Size returns the number of stored prefixes
-func (sst *StaticSearchTree) Size() int {
-	return len(sst.tree)
+// Version returns the version of the tree the snapshot was taken from
+func (snap *SearchSnapshot) Version() uint64 {
+	return snap.version
+}
+
+// Search performs a prefix search against the snapshot
+func (snap *SearchSnapshot) Search(query string) []string {
+	return snap.sst.Search(query)
+}
+
+// SearchAfter returns up to limit matches that follow cursor in Search order, along
+// with the cursor for the next page. An empty cursor starts from the first match,
+// a cursor that is not among the matches yields an empty page, and an empty next
+// cursor means there are no further pages. DefaultLimit does not cap the pages.
+func (snap *SearchSnapshot) SearchAfter(query, cursor string, limit int) ([]string, string) {
+	snap.sst.mu.RLock()
+	matches := snap.sst.search(query)
+	snap.sst.mu.RUnlock()
+	start := 0
+	if cursor != "" {
+		start = len(matches)
+		for i, word := range matches {
+			if word == cursor {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if limit <= 0 || start >= len(matches) {
+		return []string{}, ""
+	}
+	end := start + limit
+	if end >= len(matches) {
+		return matches[start:], ""
+	}
+	return matches[start:end], matches[end-1]
+}
+
+// QueryExplanation describes how a query was normalized before lookup
+type QueryExplanation struct {
+	// Raw is the query as supplied by the caller
+	Raw string
+	// Normalized is the key that is actually looked up in the tree
+	Normalized string
+	// Transforms lists the normalization steps that changed the query, in order
+	Transforms []string
+	// PrefixExists reports whether the normalized query is a stored prefix
+	PrefixExists bool
+}
+
+// Explain runs query through the normalization pipeline and reports each step
+// that changed it, which helps diagnose unexpected empty results
+func (sst *StaticSearchTree) Explain(query string) QueryExplanation {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	explanation := QueryExplanation{Raw: query, Transforms: []string{}}
+	normalized := query
+	for _, t := range sst.pipeline {
+		next := t.apply(normalized)
+		if next != normalized {
+			explanation.Transforms = append(explanation.Transforms, t.name)
+		}
+		normalized = next
+	}
+	explanation.Normalized = normalized
+	explanation.PrefixExists = sst.hasPrefix(normalized)
+	return explanation
+}
+
+// PathOf returns the compressed edge labels leading from the root of the radix
+// trie to word, whose concatenation is the normalized word. It returns nil when
+// the word is not indexed or the tree does not use BackendRadix.
+func (sst *StaticSearchTree) PathOf(word string) []string {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	key := sst.normalize(word)
+	if sst.radix == nil || key == "" {
+		return nil
+	}
+	return sst.radix.path(key)
+}
+
+// DumpTo writes the tree structure to w, one "'prefix' -> [matches]" line per
+// prefix, skipping prefixes longer than maxDepth runes so that dumps of large trees
+// stay readable. A maxDepth of 0 or less dumps every prefix.
+func (sst *StaticSearchTree) DumpTo(w io.Writer, maxDepth int) error {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	
+	bw := bufio.NewWriter(w)
+	for _, prefix := range sst.prefixes() {
+		if maxDepth > 0 && utf8.RuneCountInString(prefix) > maxDepth {
+			continue
+		}
+		matches, _ := sst.lookup(prefix)
+		if _, err := fmt.Fprintf(bw, "'%s' -> %v\n", prefix, matches); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteTree writes the entire tree structure to w, one "'prefix' -> [matches]"
+// line per prefix
+func (sst *StaticSearchTree) WriteTree(w io.Writer) error {
+	return sst.DumpTo(w, 0)
+}
+
+// ToDOT writes the prefixes of the tree to w as a Graphviz DOT digraph, for
+// visualizing small trees while debugging. Each prefix is a node with an edge to
+// each of its one-character extensions, the single-character prefixes hang off
+// a root point, and prefixes that are whole indexed words are drawn as double
+// circles.
+func (sst *StaticSearchTree) ToDOT(w io.Writer) error {
+	sst.mu.RLock()
+	defer sst.mu.RUnlock()
+	
+	terminal := make(map[string]bool)
+	for _, word := range sst.indexedWords() {
+		for _, key := range tokenKeys(sst.normalize(word), sst.opts) {
+			terminal[key] = true
+		}
+	}
+	
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph sst {")
+	fmt.Fprintln(bw, "\troot [label=\"\", shape=point];")
+	for _, prefix := range sst.prefixes() {
+		shape := "circle"
+		if terminal[prefix] {
+			shape = "doublecircle"
+		}
+		fmt.Fprintf(bw, "\t%s [shape=%s];\n", dotQuote(prefix), shape)
+		
+		_, size := utf8.DecodeLastRuneInString(prefix)
+		if parent := prefix[:len(prefix)-size]; parent == "" {
+			fmt.Fprintf(bw, "\troot -> %s;\n", dotQuote(prefix))
+		} else {
+			fmt.Fprintf(bw, "\t%s -> %s;\n", dotQuote(parent), dotQuote(prefix))
+		}
+	}
+	// The buffered writer keeps its first error, so checking the last write suffices
+	if _, err := fmt.Fprintln(bw, "}"); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// dotQuote returns s as a quoted DOT identifier
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
 }
 
 // PrintTree prints the entire tree structure (for debugging)
 func (sst *StaticSearchTree) PrintTree() {
-	prefixes := sst.GetAllPrefixes()
-	for _, prefix := range prefixes {
-		fmt.Printf("'%s' -> %v\n", prefix, sst.tree[prefix])
-	}
+	sst.WriteTree(os.Stdout)
 }
 
 // Example usage and demonstration
@@ -130,7 +3440,8 @@ func main() {
 	}
 	
 	fmt.Println("Building Static Search Tree...")
-	sst := NewStaticSearchTree(words)
+	// The prefix map backend, so the tree structure can be printed below
+	sst := NewStaticSearchTree(words, WithBackend(BackendPrefixMap))
 	
 	fmt.Printf("Tree built with %d prefixes\n\n", sst.Size())
 	
@@ -166,8 +3477,9 @@ func main() {
 	}
 }
 
-// Benchmark function to test performance
-func BenchmarkSearch(sst *StaticSearchTree, queries []string, iterations int) {
+// runPerformanceTest prints the number of results for each query; it is named
+// apart from the BenchmarkSearch benchmark in main_test.go
+func runPerformanceTest(sst *StaticSearchTree, queries []string, iterations int) {
 	fmt.Printf("\n--- Performance Test (%d iterations) ---\n", iterations)
 	
 	for _, query := range queries {
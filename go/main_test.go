@@ -0,0 +1,4201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// Test basic functionality
+func TestNewStaticSearchTree(t *testing.T) {
+	words := []string{"apple", "app", "application"}
+	sst := NewStaticSearchTree(words)
+	
+	if sst == nil {
+		t.Fatal("NewStaticSearchTree returned nil")
+	}
+	
+	if sst.radix == nil {
+		t.Fatal("radix trie is nil")
+	}
+	
+	if sst.Size() == 0 {
+		t.Fatal("tree should not be empty")
+	}
+}
+
+func TestEmptyWordList(t *testing.T) {
+	words := []string{}
+	sst := NewStaticSearchTree(words)
+	
+	if sst.Size() != 0 {
+		t.Errorf("Expected size 0 for empty word list, got %d", sst.Size())
+	}
+	
+	results := sst.Search("test")
+	if len(results) != 0 {
+		t.Errorf("Expected no results for empty tree, got %v", results)
+	}
+}
+
+func TestSingleWord(t *testing.T) {
+	words := []string{"hello"}
+	sst := NewStaticSearchTree(words)
+	
+	// Should store a single trie node below the root, labelled "hello"
+	expectedSize := 1
+	if sst.Size() != expectedSize {
+		t.Errorf("Expected size %d, got %d", expectedSize, sst.Size())
+	}
+	
+	// Test each prefix
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"h", []string{"hello"}},
+		{"he", []string{"hello"}},
+		{"hel", []string{"hello"}},
+		{"hell", []string{"hello"}},
+		{"hello", []string{"hello"}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.Search(tc.query)
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("Search('%s'): expected %v, got %v", tc.query, tc.expected, results)
+		}
+	}
+}
+
+func TestBasicSearch(t *testing.T) {
+	words := []string{"apple", "app", "application", "banana", "band"}
+	sst := NewStaticSearchTree(words)
+	
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"app", []string{"app", "apple", "application"}},
+		{"appl", []string{"apple", "application"}},
+		{"ban", []string{"banana", "band"}},
+		{"bana", []string{"banana"}},
+		{"xyz", []string{}},
+		{"", []string{}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.Search(tc.query)
+		sort.Strings(results)
+		sort.Strings(tc.expected)
+		
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("Search('%s'): expected %v, got %v", tc.query, tc.expected, results)
+		}
+	}
+}
+
+func TestCaseInsensitivity(t *testing.T) {
+	words := []string{"Apple", "BANANA", "CaR"}
+	sst := NewStaticSearchTree(words)
+	
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"app", []string{"Apple"}},
+		{"APP", []string{"Apple"}},
+		{"ApP", []string{"Apple"}},
+		{"ban", []string{"BANANA"}},
+		{"BAN", []string{"BANANA"}},
+		{"car", []string{"CaR"}},
+		{"CAR", []string{"CaR"}},
+		{"Ca", []string{"CaR"}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.Search(tc.query)
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("Search('%s'): expected %v, got %v", tc.query, tc.expected, results)
+		}
+	}
+}
+
+func TestDuplicateWords(t *testing.T) {
+	words := []string{"apple", "apple", "banana", "apple"}
+	sst := NewStaticSearchTree(words)
+	
+	results := sst.Search("app")
+	// Should only contain "apple" once, despite being in the input multiple times
+	expected := []string{"apple"}
+	
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search with duplicates: expected %v, got %v", expected, results)
+	}
+}
+
+func TestSearchWithLimit(t *testing.T) {
+	words := []string{"app", "apple", "application", "apply", "approach"}
+	sst := NewStaticSearchTree(words)
+	
+	testCases := []struct {
+		query    string
+		limit    int
+		maxLen   int
+	}{
+		{"app", 2, 2},
+		{"app", 10, 5}, // Should return all 5 matches
+		{"app", 0, 0},
+		{"xyz", 5, 0}, // No matches
+	}
+	
+	for _, tc := range testCases {
+		results := sst.SearchWithLimit(tc.query, tc.limit)
+		if len(results) > tc.maxLen {
+			t.Errorf("SearchWithLimit('%s', %d): expected max %d results, got %d", 
+				tc.query, tc.limit, tc.maxLen, len(results))
+		}
+		if tc.limit > 0 && len(results) > tc.limit {
+			t.Errorf("SearchWithLimit('%s', %d): exceeded limit, got %d results", 
+				tc.query, tc.limit, len(results))
+		}
+	}
+}
+
+func TestGetAllPrefixes(t *testing.T) {
+	words := []string{"hi", "hello"}
+	sst := NewStaticSearchTree(words)
+	
+	prefixes := sst.GetAllPrefixes()
+	
+	// Expected prefixes: h, he, hel, hell, hello, hi
+	expectedPrefixes := []string{"h", "he", "hel", "hell", "hello", "hi"}
+	sort.Strings(prefixes)
+	sort.Strings(expectedPrefixes)
+	
+	if !reflect.DeepEqual(prefixes, expectedPrefixes) {
+		t.Errorf("GetAllPrefixes(): expected %v, got %v", expectedPrefixes, prefixes)
+	}
+}
+
+func TestSize(t *testing.T) {
+	testCases := []struct {
+		words            []string
+		expectedSize     int
+		expectedPrefixes int
+	}{
+		{[]string{}, 0, 0},
+		{[]string{"a"}, 1, 1},
+		{[]string{"ab"}, 1, 2}, // "ab"; "a", "ab"
+		{[]string{"abc"}, 1, 3}, // "abc"; "a", "ab", "abc"
+		{[]string{"a", "ab"}, 2, 2}, // "a" -> "b"; "a", "ab" (no duplicates)
+		{[]string{"cat", "car"}, 3, 4}, // "ca" -> "t", "r"; "c", "ca", "cat", "car"
+	}
+	
+	for _, tc := range testCases {
+		sst := NewStaticSearchTree(tc.words)
+		if sst.Size() != tc.expectedSize {
+			t.Errorf("Size() for words %v: expected %d, got %d", 
+				tc.words, tc.expectedSize, sst.Size())
+		}
+		sst = NewStaticSearchTree(tc.words, WithBackend(BackendPrefixMap))
+		if sst.Size() != tc.expectedPrefixes {
+			t.Errorf("Size() for words %v with prefix map backend: expected %d, got %d", 
+				tc.words, tc.expectedPrefixes, sst.Size())
+		}
+	}
+}
+
+func TestMergeDeduplicate(t *testing.T) {
+	testCases := []struct {
+		slice1   []string
+		slice2   []string
+		expected []string
+	}{
+		{[]string{"a", "b"}, []string{"c", "d"}, []string{"a", "b", "c", "d"}},
+		{[]string{"a", "b"}, []string{"b", "c"}, []string{"a", "b", "c"}},
+		{[]string{}, []string{"a", "b"}, []string{"a", "b"}},
+		{[]string{"a", "b"}, []string{}, []string{"a", "b"}},
+		{[]string{}, []string{}, []string{}},
+		{[]string{"a", "a", "b"}, []string{"b", "c", "c"}, []string{"a", "b", "c"}},
+	}
+	
+	for _, tc := range testCases {
+		result := mergeDeduplicate(tc.slice1, tc.slice2)
+		sort.Strings(result)
+		sort.Strings(tc.expected)
+		
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("mergeDeduplicate(%v, %v): expected %v, got %v", 
+				tc.slice1, tc.slice2, tc.expected, result)
+		}
+	}
+}
+
+func TestLargeDataset(t *testing.T) {
+	// Generate a larger dataset
+	words := make([]string, 1000)
+	for i := 0; i < 1000; i++ {
+		words[i] = fmt.Sprintf("word%d", i)
+	}
+	
+	sst := NewStaticSearchTree(words)
+	
+	// Test that it builds successfully
+	if sst.Size() == 0 {
+		t.Error("Large dataset should produce non-empty tree")
+	}
+	
+	// Test some searches
+	results := sst.Search("word1")
+	if len(results) == 0 {
+		t.Error("Should find matches for 'word1' prefix")
+	}
+	
+	// Test that results contain expected words
+	found := false
+	for _, word := range results {
+		if strings.HasPrefix(word, "word1") {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Error("Results should contain words starting with 'word1'")
+	}
+}
+
+func TestSpecialCharacters(t *testing.T) {
+	words := []string{"hello-world", "hello_world", "hello.world", "hello world"}
+	sst := NewStaticSearchTree(words)
+	
+	// Test searching with special characters
+	testCases := []struct {
+		query    string
+		minCount int // Minimum expected results
+	}{
+		{"hello", 4}, // Should match all variants
+		{"hello-", 1},
+		{"hello_", 1},
+		{"hello.", 1},
+		{"hello ", 1},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.Search(tc.query)
+		if len(results) < tc.minCount {
+			t.Errorf("Search('%s'): expected at least %d results, got %d (%v)", 
+				tc.query, tc.minCount, len(results), results)
+		}
+	}
+}
+
+func TestUnicodeCharacters(t *testing.T) {
+	words := []string{"café", "naïve", "résumé", "jalapeño"}
+	sst := NewStaticSearchTree(words)
+	
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"caf", []string{"café"}},
+		{"naï", []string{"naïve"}},
+		{"rés", []string{"résumé"}},
+		{"jal", []string{"jalapeño"}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.Search(tc.query)
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("Search('%s'): expected %v, got %v", tc.query, tc.expected, results)
+		}
+	}
+}
+
+// Benchmark tests
+func BenchmarkBuild(b *testing.B) {
+	words := make([]string, 1000)
+	for i := 0; i < 1000; i++ {
+		words[i] = fmt.Sprintf("word%d", i)
+	}
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewStaticSearchTree(words)
+	}
+}
+
+func BenchmarkBuildWithCapacityHint(b *testing.B) {
+	words := make([]string, 1000)
+	for i := 0; i < 1000; i++ {
+		words[i] = fmt.Sprintf("word%d", i)
+	}
+	hint := NewStaticSearchTree(words, WithBackend(BackendPrefixMap)).Size()
+	
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewStaticSearchTree(words, WithBackend(BackendPrefixMap), WithCapacityHint(hint))
+	}
+}
+
+// sameIndex reports whether a and b store identical prefix maps or radix tries
+func sameIndex(a, b *StaticSearchTree) bool {
+	return reflect.DeepEqual(a.tree, b.tree) && reflect.DeepEqual(a.radix, b.radix)
+}
+
+// randomWords returns n deterministic pseudo-random lowercase words
+func randomWords(n int) []string {
+	rng := rand.New(rand.NewSource(1))
+	words := make([]string, n)
+	for i := range words {
+		buf := make([]byte, 4+rng.Intn(8))
+		for j := range buf {
+			buf[j] = byte('a' + rng.Intn(26))
+		}
+		words[i] = string(buf)
+	}
+	return words
+}
+
+func benchmarkBackendMemory(b *testing.B, backend Backend) {
+	words := randomWords(50000)
+	var before, after runtime.MemStats
+	var retained uint64
+	
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		sst := NewStaticSearchTree(nil, WithBackend(backend))
+		sst.InsertAll(words)
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		retained = after.HeapAlloc - before.HeapAlloc
+		runtime.KeepAlive(sst)
+	}
+	b.ReportMetric(float64(retained), "heap-bytes/tree")
+}
+
+func BenchmarkMemoryPrefixMap(b *testing.B) {
+	benchmarkBackendMemory(b, BackendPrefixMap)
+}
+
+func BenchmarkMemoryRadix(b *testing.B) {
+	benchmarkBackendMemory(b, BackendRadix)
+}
+
+func BenchmarkSearchRadix(b *testing.B) {
+	words := make([]string, 1000)
+	for i := 0; i < 1000; i++ {
+		words[i] = fmt.Sprintf("word%d", i)
+	}
+	sst := NewStaticSearchTree(words, WithBackend(BackendRadix))
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sst.Search("word1")
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	words := make([]string, 1000)
+	for i := 0; i < 1000; i++ {
+		words[i] = fmt.Sprintf("word%d", i)
+	}
+	sst := NewStaticSearchTree(words, WithBackend(BackendPrefixMap))
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sst.Search("word1")
+	}
+}
+
+func BenchmarkSearchWithLimit(b *testing.B) {
+	words := make([]string, 1000)
+	for i := 0; i < 1000; i++ {
+		words[i] = fmt.Sprintf("word%d", i)
+	}
+	sst := NewStaticSearchTree(words)
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sst.SearchWithLimit("word1", 10)
+	}
+}
+
+func BenchmarkSearchFirstTen(b *testing.B) {
+	sst := NewStaticSearchTree(randomWords(5000))
+	
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, word := range sst.Search("a")[:10] {
+			_ = word
+		}
+	}
+}
+
+func BenchmarkSearchIterFirstTen(b *testing.B) {
+	sst := NewStaticSearchTree(randomWords(5000))
+	
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for range sst.SearchIter("a") {
+			if n++; n == 10 {
+				break
+			}
+		}
+	}
+}
+
+// buildQuadratic is the original O(N²·L) prefix map construction, kept as the
+// reference for TestBuildEquivalence and BenchmarkBuildQuadratic
+func buildQuadratic(sst *StaticSearchTree, words []string) {
+	sort.Strings(words)
+	keys := make([]string, len(words))
+	for i, word := range words {
+		keys[i] = sst.normalize(word)
+	}
+	for _, key := range keys {
+		for i := 1; i <= len(indexedPrefix(key, sst.opts.MaxPrefixLength)); i++ {
+			prefix := key[:i]
+			var matches []string
+			for j, candidate := range words {
+				if strings.HasPrefix(keys[j], prefix) {
+					matches = append(matches, candidate)
+				}
+			}
+			if existing, exists := sst.tree[prefix]; exists {
+				sst.tree[prefix] = mergeDeduplicate(existing, matches)
+			} else {
+				sst.tree[prefix] = matches
+			}
+		}
+	}
+}
+
+func BenchmarkBuild5000(b *testing.B) {
+	words := randomWords(5000)
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewStaticSearchTree(words, WithBackend(BackendPrefixMap))
+	}
+}
+
+func BenchmarkBuildQuadratic5000(b *testing.B) {
+	words := randomWords(5000)
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buildQuadratic(newTree(Options{Backend: BackendPrefixMap}), words)
+	}
+}
+
+func BenchmarkBuildSerial50000(b *testing.B) {
+	words := randomWords(50000)
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewStaticSearchTree(append([]string(nil), words...))
+	}
+}
+
+func BenchmarkBuildParallel50000(b *testing.B) {
+	words := randomWords(50000)
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewStaticSearchTreeParallel(append([]string(nil), words...), runtime.NumCPU())
+	}
+}
+
+func BenchmarkSearchNoMatch(b *testing.B) {
+	benchmarkSearchNoMatch(b, false)
+}
+
+func BenchmarkSearchNoMatchBloom(b *testing.B) {
+	benchmarkSearchNoMatch(b, true)
+}
+
+// benchmarkSearchNoMatch searches a mix in which nine of every ten queries match
+// nothing and reports how many queries reached the prefix map
+func benchmarkSearchNoMatch(b *testing.B, bloom bool) {
+	sst := NewStaticSearchTree(randomWords(50000), WithBackend(BackendPrefixMap), WithBloomFilter(bloom))
+	queries := make([]string, 100)
+	for i := range queries {
+		if i%10 == 0 {
+			queries[i] = "ab"
+		} else {
+			queries[i] = fmt.Sprintf("q%dzz", i)
+		}
+	}
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sst.Search(queries[i%len(queries)])
+	}
+	b.StopTimer()
+	lookups := float64(b.N)
+	if sst.bloom != nil {
+		lookups -= float64(sst.bloom.rejected.Load())
+	}
+	b.ReportMetric(lookups/float64(b.N), "map-lookups/op")
+}
+
+// Example test demonstrating usage
+func ExampleStaticSearchTree() {
+	words := []string{"apple", "app", "application", "banana"}
+	sst := NewStaticSearchTree(words)
+	
+	results := sst.Search("app")
+	sort.Strings(results) // Sort for consistent output
+	fmt.Println(results)
+	// Output: [app apple application]
+}
+
+// Test that search results are not modifiable (defensive copying)
+func TestSearchResultsImmutability(t *testing.T) {
+	words := []string{"apple", "app"}
+	sst := NewStaticSearchTree(words)
+	
+	results1 := sst.Search("app")
+	results2 := sst.Search("app")
+	
+	// Modify first result set
+	if len(results1) > 0 {
+		results1[0] = "modified"
+	}
+	
+	// Second result set should be unchanged
+	if len(results2) > 0 && results2[0] == "modified" {
+		t.Error("Search results should be independent copies")
+	}
+}
+
+func TestMinimalCoveringPrefixes(t *testing.T) {
+	words := []string{
+		"apple", "application", "apply", "apricot",
+		"banana", "band", "bandana", "bank",
+		"car", "card", "care", "careful", "cat",
+	}
+	sst := NewStaticSearchTree(words)
+	
+	for _, maxPerPrefix := range []int{1, 2, 3, 100} {
+		prefixes := sst.MinimalCoveringPrefixes(maxPerPrefix)
+		
+		covered := make(map[string]bool)
+		for _, prefix := range prefixes {
+			matches := sst.Search(prefix)
+			if len(matches) > maxPerPrefix && !contains(matches, prefix) {
+				t.Errorf("MinimalCoveringPrefixes(%d): prefix '%s' matches %d words", 
+					maxPerPrefix, prefix, len(matches))
+			}
+			for _, word := range matches {
+				covered[word] = true
+			}
+		}
+		
+		for _, word := range words {
+			if !covered[word] {
+				t.Errorf("MinimalCoveringPrefixes(%d): word '%s' not covered by %v", 
+					maxPerPrefix, word, prefixes)
+			}
+		}
+	}
+	
+	expected := []string{"a", "b", "c"}
+	if prefixes := sst.MinimalCoveringPrefixes(100); !reflect.DeepEqual(prefixes, expected) {
+		t.Errorf("MinimalCoveringPrefixes(100): expected %v, got %v", expected, prefixes)
+	}
+}
+
+func contains(words []string, target string) bool {
+	for _, word := range words {
+		if word == target {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAlphabeticalBuckets(t *testing.T) {
+	words := []string{"car", "card", "Care", "cat", "cob", "cup", "dog"}
+	sst := NewStaticSearchTree(words)
+	
+	expected := map[rune][]string{
+		'a': {"Care", "car", "card", "cat"},
+		'o': {"cob"},
+		'u': {"cup"},
+	}
+	if buckets := sst.AlphabeticalBuckets("c"); !reflect.DeepEqual(buckets, expected) {
+		t.Errorf("AlphabeticalBuckets('c'): expected %v, got %v", expected, buckets)
+	}
+	
+	expected = map[rune][]string{
+		0:   {"car"},
+		'd': {"card"},
+		'e': {"Care"},
+	}
+	if buckets := sst.AlphabeticalBuckets("CAR"); !reflect.DeepEqual(buckets, expected) {
+		t.Errorf("AlphabeticalBuckets('CAR'): expected %v, got %v", expected, buckets)
+	}
+	
+	if buckets := sst.AlphabeticalBuckets("z"); len(buckets) != 0 {
+		t.Errorf("AlphabeticalBuckets('z'): expected no buckets, got %v", buckets)
+	}
+}
+
+func TestWhitespaceInsensitiveDedup(t *testing.T) {
+	words := []string{"hello world", "hello-world", "hello_world", "hello.world", "helloworld", "help"}
+	
+	sst := NewStaticSearchTree(words)
+	if results := sst.Search("hello"); len(results) != 5 {
+		t.Errorf("Search('hello') without dedup: expected 5 results, got %v", results)
+	}
+	
+	sst = NewStaticSearchTree(words, WithWhitespaceInsensitiveDedup(true))
+	expected := []string{"hello world"}
+	if results := sst.Search("hello"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search('hello') with dedup: expected %v, got %v", expected, results)
+	}
+	
+	expected = []string{"hello world", "help"}
+	if results := sst.Search("hel"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search('hel') with dedup: expected %v, got %v", expected, results)
+	}
+	
+	expected = []string{"hello_world"}
+	if results := sst.Search("hello_"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search('hello_') with dedup: expected %v, got %v", expected, results)
+	}
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"app", "apple", "banana"})
+	snap := sst.Snapshot()
+	
+	sst.build([]string{"apricot", "application"})
+	
+	if snap.Version() == sst.version {
+		t.Errorf("Snapshot version %d should differ from mutated tree version", snap.Version())
+	}
+	
+	expected := []string{"app", "apple"}
+	if results := snap.Search("ap"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("snapshot Search('ap'): expected %v, got %v", expected, results)
+	}
+	if results := sst.Search("ap"); len(results) != 4 {
+		t.Errorf("tree Search('ap') after mutation: expected 4 results, got %v", results)
+	}
+}
+
+func TestSnapshotSearchAfter(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"car", "card", "care", "careful", "cart"})
+	snap := sst.Snapshot()
+	
+	var pages [][]string
+	cursor := ""
+	for {
+		page, next := snap.SearchAfter("car", cursor, 2)
+		pages = append(pages, page)
+		if next == "" {
+			break
+		}
+		sst.build([]string{"carbon", "cargo"})
+		cursor = next
+	}
+	
+	expected := [][]string{{"car", "card"}, {"care", "careful"}, {"cart"}}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Errorf("SearchAfter pages: expected %v, got %v", expected, pages)
+	}
+	
+	if page, next := snap.SearchAfter("car", "cart", 2); len(page) != 0 || next != "" {
+		t.Errorf("SearchAfter past the end: expected empty page, got %v (next %q)", page, next)
+	}
+}
+
+func TestWriteWords(t *testing.T) {
+	words := []string{"banana", "Apple", "app", "banana", "cherry"}
+	sst := NewStaticSearchTree(words)
+	
+	var buf bytes.Buffer
+	if err := sst.WriteWords(&buf); err != nil {
+		t.Fatalf("WriteWords returned error: %v", err)
+	}
+	
+	var readBack []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		readBack = append(readBack, scanner.Text())
+	}
+	
+	expected := []string{"Apple", "app", "banana", "cherry"}
+	if !reflect.DeepEqual(sst.Words(), expected) {
+		t.Errorf("Words(): expected %v, got %v", expected, sst.Words())
+	}
+	if !reflect.DeepEqual(readBack, sst.Words()) {
+		t.Errorf("WriteWords output: expected %v, got %v", sst.Words(), readBack)
+	}
+}
+
+func TestSearchShuffled(t *testing.T) {
+	words := []string{"car", "card", "care", "careful", "cargo", "carpet", "cart", "carton"}
+	sst := NewStaticSearchTree(words)
+	
+	first := sst.SearchShuffled("car", 42)
+	second := sst.SearchShuffled("car", 42)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("SearchShuffled with the same seed: got %v and %v", first, second)
+	}
+	
+	sorted := append([]string(nil), first...)
+	sort.Strings(sorted)
+	if !reflect.DeepEqual(sorted, sst.Search("car")) {
+		t.Errorf("SearchShuffled should contain the same matches as Search, got %v", first)
+	}
+	
+	differs := false
+	for seed := int64(1); seed <= 10; seed++ {
+		if !reflect.DeepEqual(sst.SearchShuffled("car", seed), first) {
+			differs = true
+			break
+		}
+	}
+	if !differs {
+		t.Error("SearchShuffled with different seeds should produce different orderings")
+	}
+	
+	if results := sst.SearchShuffled("xyz", 42); len(results) != 0 {
+		t.Errorf("SearchShuffled('xyz'): expected no results, got %v", results)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"Apple", "banana"}, WithTrimSpace(true))
+	
+	explanation := sst.Explain("  APP ")
+	expected := QueryExplanation{
+		Raw:          "  APP ",
+		Normalized:   "app",
+		Transforms:   []string{"trim", "lowercase"},
+		PrefixExists: true,
+	}
+	if !reflect.DeepEqual(explanation, expected) {
+		t.Errorf("Explain('  APP '): expected %+v, got %+v", expected, explanation)
+	}
+	
+	explanation = sst.Explain("ban")
+	if len(explanation.Transforms) != 0 || explanation.Normalized != "ban" || !explanation.PrefixExists {
+		t.Errorf("Explain('ban'): expected no transforms and an existing prefix, got %+v", explanation)
+	}
+	
+	explanation = sst.Explain("Cherry")
+	if explanation.PrefixExists || explanation.Normalized != "cherry" {
+		t.Errorf("Explain('Cherry'): expected missing prefix 'cherry', got %+v", explanation)
+	}
+}
+
+func TestTrimSpace(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"  apple ", "apricot"}, WithTrimSpace(true))
+	
+	expected := []string{"  apple ", "apricot"}
+	if results := sst.Search(" ap"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search(' ap'): expected %v, got %v", expected, results)
+	}
+	if results := sst.Search(" "); len(results) != 0 {
+		t.Errorf("Search(' '): expected no results, got %v", results)
+	}
+}
+
+func TestNewStaticSearchTreeWithDeadline(t *testing.T) {
+	words := make([]string, 1000)
+	for i := range words {
+		words[i] = fmt.Sprintf("word%d", i)
+	}
+	full := NewStaticSearchTree(append([]string(nil), words...))
+	
+	sst, indexed := NewStaticSearchTreeWithDeadline(words, time.Now().Add(-time.Second))
+	if indexed != 0 || sst.Size() != 0 {
+		t.Errorf("expired deadline: expected empty tree, got %d indexed and size %d", indexed, sst.Size())
+	}
+	
+	sst, indexed = NewStaticSearchTreeWithDeadline(words, time.Now().Add(time.Millisecond))
+	if indexed < 0 || indexed > len(words) {
+		t.Fatalf("indexed count %d out of range", indexed)
+	}
+	
+	// The partial tree must be exactly the tree of the words it reports as indexed
+	partial := NewStaticSearchTree(append([]string(nil), words[:indexed]...))
+	if !sameIndex(sst, partial) {
+		t.Errorf("partial tree with %d words is not consistent with a full build of them", indexed)
+	}
+	
+	sst.InsertAll(words[indexed:])
+	if !sameIndex(sst, full) {
+		t.Error("InsertAll of the remaining words should produce the full tree")
+	}
+	
+	sst, indexed = NewStaticSearchTreeWithDeadline(words, time.Now().Add(time.Hour))
+	if indexed != len(words) || !sameIndex(sst, full) {
+		t.Errorf("generous deadline: expected all %d words indexed, got %d", len(words), indexed)
+	}
+}
+
+func TestInsertAll(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"banana", "apple"})
+	
+	if added := sst.InsertAll([]string{"app", "apple", "Apricot", "app"}); added != 2 {
+		t.Errorf("InsertAll: expected 2 new words, got %d", added)
+	}
+	
+	expected := NewStaticSearchTree([]string{"banana", "apple", "app", "Apricot"})
+	if !sameIndex(sst, expected) {
+		t.Errorf("InsertAll: expected tree %v, got %v", expected.GetAllPrefixes(), sst.GetAllPrefixes())
+	}
+}
+
+func TestMergeSearchResults(t *testing.T) {
+	words := []string{
+		"car", "card", "care", "careful", "cargo", "carpet", "cart", "cat",
+		"apple", "banana",
+	}
+	single := NewStaticSearchTree(append([]string(nil), words...))
+	
+	// Split the words across three shards, with "card" indexed in two of them
+	shards := []*StaticSearchTree{
+		NewStaticSearchTree([]string{"car", "careful", "cart", "apple"}),
+		NewStaticSearchTree([]string{"card", "cargo", "banana"}),
+		NewStaticSearchTree([]string{"card", "care", "carpet", "cat"}),
+	}
+	
+	for _, query := range []string{"car", "ca", "a", "x"} {
+		var perShard [][]string
+		for _, shard := range shards {
+			perShard = append(perShard, shard.Search(query))
+		}
+		merged := MergeSearchResults(perShard...)
+		if expected := single.Search(query); !reflect.DeepEqual(merged, expected) {
+			t.Errorf("MergeSearchResults for '%s': expected %v, got %v", query, expected, merged)
+		}
+	}
+	
+	if merged := MergeSearchResults(); len(merged) != 0 {
+		t.Errorf("MergeSearchResults(): expected empty result, got %v", merged)
+	}
+}
+
+func TestDiskIndex(t *testing.T) {
+	words := []string{
+		"apple", "application", "apply", "Apricot",
+		"banana", "band", "bandana", "bank",
+		"car", "card", "care", "careful", "café",
+	}
+	sst := NewStaticSearchTree(words)
+	
+	path := filepath.Join(t.TempDir(), "words.idx")
+	if err := sst.WriteDiskIndex(path); err != nil {
+		t.Fatalf("WriteDiskIndex returned error: %v", err)
+	}
+	
+	dst, err := OpenDiskIndex(path)
+	if err != nil {
+		t.Fatalf("OpenDiskIndex returned error: %v", err)
+	}
+	defer dst.Close()
+	
+	if prefixes := len(sst.GetAllPrefixes()); dst.Size() != prefixes {
+		t.Errorf("Size(): expected %d, got %d", prefixes, dst.Size())
+	}
+	
+	var searcher PrefixSearcher = dst
+	for _, query := range []string{"a", "APP", "ban", "car", "caf", "café", "xyz", ""} {
+		expected := sst.Search(query)
+		if results := searcher.Search(query); !reflect.DeepEqual(results, expected) {
+			t.Errorf("disk Search('%s'): expected %v, got %v", query, expected, results)
+		}
+	}
+}
+
+func TestDiskIndexColdBuckets(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "banana", "cherry", "date"})
+	path := filepath.Join(t.TempDir(), "words.idx")
+	if err := sst.WriteDiskIndex(path); err != nil {
+		t.Fatalf("WriteDiskIndex returned error: %v", err)
+	}
+	
+	dst, err := OpenDiskIndex(path)
+	if err != nil {
+		t.Fatalf("OpenDiskIndex returned error: %v", err)
+	}
+	defer dst.Close()
+	dst.cache = newLRUCache(2)
+	
+	for _, query := range []string{"a", "b", "c"} {
+		dst.Search(query)
+	}
+	if dst.cache.len() != 2 || dst.cache.misses != 3 {
+		t.Errorf("expected 2 cached buckets after 3 cold loads, got %d cached and %d misses", 
+			dst.cache.len(), dst.cache.misses)
+	}
+	
+	// "a" was evicted and must be reloaded from disk
+	expected := []string{"apple"}
+	if results := dst.Search("a"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search('a') after eviction: expected %v, got %v", expected, results)
+	}
+	if dst.cache.misses != 4 {
+		t.Errorf("expected evicted bucket to be reloaded, got %d misses", dst.cache.misses)
+	}
+	
+	dst.Search("a")
+	if dst.cache.hits != 1 {
+		t.Errorf("expected hot bucket to be served from cache, got %d hits", dst.cache.hits)
+	}
+}
+
+func TestOpenDiskIndexInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bogus.idx")
+	if err := os.WriteFile(path, []byte("not an index at all"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenDiskIndex(path); !errors.Is(err, ErrInvalidDiskIndex) {
+		t.Errorf("OpenDiskIndex on bogus file: expected ErrInvalidDiskIndex, got %v", err)
+	}
+}
+
+func TestOpenDiskIndexCorrupt(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewStaticSearchTree([]string{"apple", "banana"}).writeDiskIndex(&buf); err != nil {
+		t.Fatalf("writeDiskIndex returned error: %v", err)
+	}
+	valid := buf.Bytes()
+	dirOffset := binary.LittleEndian.Uint64(valid[len(valid)-8:])
+	huge := uint64(1) << 62
+	
+	// withDirectory replaces everything from the directory on
+	withDirectory := func(body []byte, dir ...uint64) []byte {
+		data := append([]byte(nil), body...)
+		offset := uint64(len(data))
+		for _, v := range dir {
+			data = binary.AppendUvarint(data, v)
+		}
+		return binary.LittleEndian.AppendUint64(data, offset)
+	}
+	hugeBucket := binary.AppendUvarint(append([]byte(nil), valid[:dirOffset]...), huge)
+	
+	tests := []struct {
+		name string
+		data []byte
+		open bool
+	}{
+		{"truncated", valid[:len(valid)/2], false},
+		{"truncated directory", withDirectory(valid[:dirOffset], 2, 1, 'a'), false},
+		{"huge options length", binary.LittleEndian.AppendUint64(binary.AppendUvarint([]byte(diskIndexMagic), huge), 0), false},
+		{"huge prefix count", withDirectory(valid[:dirOffset], huge), false},
+		{"huge prefix length", withDirectory(valid[:dirOffset], 1, huge), false},
+		{"bucket past the directory", withDirectory(valid[:dirOffset], 1, 1, 'a', dirOffset, 100), false},
+		{"huge match count", withDirectory(hugeBucket, 1, 1, 'a', dirOffset, uint64(len(hugeBucket))-dirOffset), true},
+	}
+	
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "corrupt.idx")
+		if err := os.WriteFile(path, test.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		dst, err := OpenDiskIndex(path)
+		if !test.open {
+			if err == nil {
+				dst.Close()
+				t.Errorf("OpenDiskIndex (%s): expected an error, got none", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("OpenDiskIndex (%s) returned error: %v", test.name, err)
+		}
+		if _, err := dst.SearchErr("a"); !errors.Is(err, ErrInvalidDiskIndex) {
+			t.Errorf("SearchErr('a') (%s): expected ErrInvalidDiskIndex, got %v", test.name, err)
+		}
+		dst.Close()
+	}
+}
+
+func TestPrefixDifference(t *testing.T) {
+	words := []string{"car", "card", "care", "careful", "cart", "cat"}
+	sst := NewStaticSearchTree(words)
+	
+	testCases := []struct {
+		a, b     string
+		expected []string
+	}{
+		{"car", "care", []string{"car", "card", "cart"}},
+		{"ca", "car", []string{"cat"}},
+		{"care", "car", []string{}},
+		{"car", "dog", []string{"car", "card", "care", "careful", "cart"}},
+		{"dog", "car", []string{}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.PrefixDifference(tc.a, tc.b)
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("PrefixDifference('%s', '%s'): expected %v, got %v", tc.a, tc.b, tc.expected, results)
+		}
+	}
+}
+
+func TestCapacityHint(t *testing.T) {
+	words := []string{"apple", "app", "application", "banana", "band"}
+	expected := NewStaticSearchTree(append([]string(nil), words...), WithBackend(BackendPrefixMap))
+	
+	for _, hint := range []int{-1, 0, 1, 1000} {
+		sst := NewStaticSearchTree(append([]string(nil), words...), WithBackend(BackendPrefixMap), WithCapacityHint(hint))
+		if !reflect.DeepEqual(sst.tree, expected.tree) {
+			t.Errorf("WithCapacityHint(%d): tree differs from unhinted build", hint)
+		}
+	}
+}
+
+func TestSearchFieldPrefixNormalizeWhitespace(t *testing.T) {
+	m := NewMultiFieldSearchTree(WithNormalizeWhitespace(true))
+	m.AddRecord(1, map[string]string{"title": "  apple  pie "})
+	m.AddRecord(2, map[string]string{"title": "apple pie"})
+	
+	if result := m.Search("title", "app"); !reflect.DeepEqual(result, []string{"apple pie"}) {
+		t.Errorf("Search('title', 'app') with WithNormalizeWhitespace: expected [apple pie], got %v", result)
+	}
+	if ids := m.SearchFieldPrefix("title", "app"); !reflect.DeepEqual(ids, []RecordID{1, 2}) {
+		t.Errorf("SearchFieldPrefix('title', 'app') with WithNormalizeWhitespace: expected [1 2], got %v", ids)
+	}
+}
+
+func TestSearchWithFieldPrefix(t *testing.T) {
+	m := NewMultiFieldSearchTree()
+	m.AddRecord(1, map[string]string{"name": "Apple Juice", "sku": "X100"})
+	m.AddRecord(2, map[string]string{"name": "Apple Pie", "sku": "Y200"})
+	m.AddRecord(3, map[string]string{"name": "Banana Bread", "sku": "X300"})
+	m.AddRecord(4, map[string]string{"name": "applesauce", "sku": "x400"})
+	
+	expected := []RecordID{1, 2, 4}
+	if ids := m.SearchFieldPrefix("name", "app"); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("SearchFieldPrefix('name', 'app'): expected %v, got %v", expected, ids)
+	}
+	
+	testCases := []struct {
+		query1, query2 string
+		expected       []RecordID
+	}{
+		{"app", "X", []RecordID{1, 4}},
+		{"apple p", "x", []RecordID{}},
+		{"ban", "x3", []RecordID{3}},
+		{"cherry", "x", []RecordID{}},
+	}
+	
+	for _, tc := range testCases {
+		ids := m.SearchWithFieldPrefix("name", tc.query1, "sku", tc.query2)
+		if !reflect.DeepEqual(ids, tc.expected) {
+			t.Errorf("SearchWithFieldPrefix('name', '%s', 'sku', '%s'): expected %v, got %v", 
+				tc.query1, tc.query2, tc.expected, ids)
+		}
+	}
+	
+	if ids := m.SearchWithFieldPrefix("name", "app", "color", "r"); len(ids) != 0 {
+		t.Errorf("SearchWithFieldPrefix on unknown field: expected no results, got %v", ids)
+	}
+}
+
+func TestFirstCharacters(t *testing.T) {
+	words := []string{"cherry", "Apple", "apricot", "banana", "Éclair", "zebra", "écran"}
+	sst := NewStaticSearchTree(words)
+	
+	expected := []rune{'a', 'b', 'c', 'z', 'é'}
+	if chars := sst.FirstCharacters(); !reflect.DeepEqual(chars, expected) {
+		t.Errorf("FirstCharacters(): expected %q, got %q", expected, chars)
+	}
+	
+	if chars := NewStaticSearchTree([]string{}).FirstCharacters(); len(chars) != 0 {
+		t.Errorf("FirstCharacters() on empty tree: expected none, got %q", chars)
+	}
+}
+
+func TestRepeatFolding(t *testing.T) {
+	words := []string{"hello", "apple", "help", "Book"}
+	
+	sst := NewStaticSearchTree(append([]string(nil), words...))
+	if results := sst.Search("heeello"); len(results) != 0 {
+		t.Errorf("Search('heeello') without folding: expected no results, got %v", results)
+	}
+	
+	sst = NewStaticSearchTree(append([]string(nil), words...), WithRepeatFolding(true))
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"heeello", []string{"hello"}},
+		{"hellllo", []string{"hello"}},
+		{"aaapple", []string{"apple"}},
+		{"hel", []string{"hello", "help"}},
+		{"BOOOK", []string{"Book"}},
+		{"bok", []string{"Book"}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.Search(tc.query)
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("Search('%s') with folding: expected %v, got %v", tc.query, tc.expected, results)
+		}
+	}
+	
+	if explanation := sst.Explain("Heeello"); !reflect.DeepEqual(explanation.Transforms, []string{"lowercase", "fold-repeats"}) {
+		t.Errorf("Explain('Heeello'): expected lowercase and fold-repeats, got %v", explanation.Transforms)
+	}
+}
+
+func TestRadixBackendMatchesPrefixMap(t *testing.T) {
+	words := []string{
+		"apple", "app", "application", "Apricot", "apple",
+		"banana", "Band", "bandana", "bank",
+		"car", "card", "care", "careful", "cat",
+		"café", "naïve", "hello world", "hello-world",
+	}
+	prefixMap := NewStaticSearchTree(append([]string(nil), words...))
+	radix := NewStaticSearchTree(append([]string(nil), words...), WithBackend(BackendRadix))
+	
+	if radix.tree != nil || radix.radix == nil {
+		t.Fatal("radix backend should not allocate the prefix map")
+	}
+	if radix.Size() != prefixMap.Size() {
+		t.Errorf("Size(): expected %d, got %d", prefixMap.Size(), radix.Size())
+	}
+	if !reflect.DeepEqual(radix.GetAllPrefixes(), prefixMap.GetAllPrefixes()) {
+		t.Errorf("GetAllPrefixes(): expected %v, got %v", prefixMap.GetAllPrefixes(), radix.GetAllPrefixes())
+	}
+	if !reflect.DeepEqual(radix.Words(), prefixMap.Words()) {
+		t.Errorf("Words(): expected %v, got %v", prefixMap.Words(), radix.Words())
+	}
+	
+	for _, prefix := range append(prefixMap.GetAllPrefixes(), "", "xyz", "APP", "carefully") {
+		expected := prefixMap.Search(prefix)
+		if results := radix.Search(prefix); !reflect.DeepEqual(results, expected) {
+			t.Errorf("radix Search('%s'): expected %v, got %v", prefix, expected, results)
+		}
+		if results := radix.SearchWithLimit(prefix, 2); !reflect.DeepEqual(results, prefixMap.SearchWithLimit(prefix, 2)) {
+			t.Errorf("radix SearchWithLimit('%s', 2): got %v", prefix, results)
+		}
+	}
+}
+
+func TestRadixEdgeSplitting(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"romane", "romanus", "romulus", "rubens", "ruber", "rubicon"}, 
+		WithBackend(BackendRadix))
+	
+	// The root has a single "r" edge that branches into "om" and "ub"
+	if len(sst.radix.edges) != 1 || sst.radix.edges[0].label != "r" {
+		t.Fatalf("expected a single root edge 'r', got %+v", sst.radix.edges)
+	}
+	var labels []string
+	for _, edge := range sst.radix.edges[0].node.edges {
+		labels = append(labels, edge.label)
+	}
+	if expected := []string{"om", "ub"}; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("expected edges %v below 'r', got %v", expected, labels)
+	}
+	
+	expected := []string{"romane", "romanus"}
+	if results := sst.Search("roma"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search('roma'): expected %v, got %v", expected, results)
+	}
+	if added := sst.InsertAll([]string{"rom", "romane"}); added != 1 {
+		t.Errorf("InsertAll: expected 1 new word, got %d", added)
+	}
+	expected = []string{"rom", "romane", "romanus", "romulus"}
+	if results := sst.Search("rom"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search('rom') after insert: expected %v, got %v", expected, results)
+	}
+}
+
+func TestRadixSnapshotIsolation(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"app", "apple"}, WithBackend(BackendRadix))
+	snap := sst.Snapshot()
+	sst.InsertAll([]string{"apricot", "ap"})
+	
+	expected := []string{"app", "apple"}
+	if results := snap.Search("ap"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("snapshot Search('ap'): expected %v, got %v", expected, results)
+	}
+}
+
+func TestSearchRankedByLength(t *testing.T) {
+	words := []string{"application", "apply", "app", "apple", "appeal", "apt", "banana"}
+	sst := NewStaticSearchTree(words)
+	
+	testCases := []struct {
+		query    string
+		limit    int
+		expected []string
+	}{
+		{"ap", 10, []string{"app", "apt", "apple", "apply", "appeal", "application"}},
+		{"app", 3, []string{"app", "apple", "apply"}},
+		{"appl", 1, []string{"apple"}},
+		{"ap", 0, []string{}},
+		{"", 5, []string{}},
+		{"xyz", 5, []string{}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.SearchRankedByLength(tc.query, tc.limit)
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("SearchRankedByLength('%s', %d): expected %v, got %v", 
+				tc.query, tc.limit, tc.expected, results)
+		}
+	}
+}
+
+func TestCoverageOf(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "banana", "cherry"})
+	
+	queryLog := []string{"app", "APPLE", "apples", "ban", "kiwi", "", "cherry", "ch", "x"}
+	covered, total := sst.CoverageOf(queryLog)
+	if covered != 5 || total != 9 {
+		t.Errorf("CoverageOf: expected (5, 9), got (%d, %d)", covered, total)
+	}
+	
+	if covered, total := sst.CoverageOf(nil); covered != 0 || total != 0 {
+		t.Errorf("CoverageOf(nil): expected (0, 0), got (%d, %d)", covered, total)
+	}
+	
+	if !sst.PrefixExists("Ban") || sst.PrefixExists("bananas") {
+		t.Error("PrefixExists should be true for 'Ban' and false for 'bananas'")
+	}
+}
+
+func TestSearchChanContext(t *testing.T) {
+	words := make([]string, 100)
+	for i := range words {
+		words[i] = fmt.Sprintf("word%03d", i)
+	}
+	sst := NewStaticSearchTree(words)
+	
+	var all []string
+	for word := range sst.SearchChanContext(context.Background(), "word") {
+		all = append(all, word)
+	}
+	if !reflect.DeepEqual(all, sst.Search("word")) {
+		t.Errorf("SearchChanContext without cancellation should emit every match, got %d", len(all))
+	}
+	
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := sst.SearchChanContext(ctx, "word")
+	received := 0
+	if _, ok := <-ch; ok {
+		received++
+	}
+	cancel()
+	
+	timeout := time.After(time.Second)
+	for done := false; !done; {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				done = true
+			} else {
+				received++
+			}
+		case <-timeout:
+			t.Fatal("channel was not closed after cancellation")
+		}
+	}
+	// At most one word can race with the cancellation
+	if received > 2 {
+		t.Errorf("expected emission to stop after cancellation, received %d words", received)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree([]string{"banana", "cherry"}, WithBackend(backend))
+		inserted := []string{"banana", "cherry"}
+		
+		for _, word := range []string{"apple", "app", "Apple", "band", "apple", "application", "ban"} {
+			isNew := !contains(inserted, word)
+			if added := sst.Insert(word); added != isNew {
+				t.Errorf("backend %d: Insert('%s'): expected %v, got %v", backend, word, isNew, added)
+			}
+			if isNew {
+				inserted = append(inserted, word)
+			}
+			
+			fresh := NewStaticSearchTree(append([]string(nil), inserted...), WithBackend(backend))
+			for _, query := range []string{"a", "app", "appl", "b", "ban", "c", "x"} {
+				if results, expected := sst.Search(query), fresh.Search(query); !reflect.DeepEqual(results, expected) {
+					t.Errorf("backend %d: after Insert('%s'), Search('%s'): expected %v, got %v", 
+						backend, word, query, expected, results)
+				}
+			}
+			if sst.Size() != fresh.Size() {
+				t.Errorf("backend %d: after Insert('%s'), Size(): expected %d, got %d", 
+					backend, word, fresh.Size(), sst.Size())
+			}
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	words := []string{"app", "apple", "application", "Apricot", "banana", "band"}
+	queries := []string{"a", "ap", "app", "appl", "apr", "b", "ban", "x"}
+	
+	testCases := []struct {
+		word     string
+		removed  bool
+		expected []string
+	}{
+		{"app", true, []string{"apple", "application", "Apricot", "banana", "band"}},
+		{"APRICOT", true, []string{"app", "apple", "application", "banana", "band"}},
+		{"band", true, []string{"app", "apple", "application", "Apricot", "banana"}},
+		{"ban", false, words},
+		{"cherry", false, words},
+		{"", false, words},
+	}
+	
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		for _, tc := range testCases {
+			sst := NewStaticSearchTree(append([]string(nil), words...), WithBackend(backend))
+			if removed := sst.Delete(tc.word); removed != tc.removed {
+				t.Errorf("backend %d: Delete('%s'): expected %v, got %v", backend, tc.word, tc.removed, removed)
+			}
+			
+			fresh := NewStaticSearchTree(append([]string(nil), tc.expected...), WithBackend(backend))
+			if sst.Size() != fresh.Size() {
+				t.Errorf("backend %d: after Delete('%s'), Size(): expected %d, got %d", 
+					backend, tc.word, fresh.Size(), sst.Size())
+			}
+			for _, query := range queries {
+				if results, expected := sst.Search(query), fresh.Search(query); !reflect.DeepEqual(results, expected) {
+					t.Errorf("backend %d: after Delete('%s'), Search('%s'): expected %v, got %v", 
+						backend, tc.word, query, expected, results)
+				}
+			}
+		}
+	}
+}
+
+func TestDeleteCaseVariants(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"Apple", "apple", "apples"})
+	if !sst.Delete("APPLE") {
+		t.Fatal("Delete('APPLE') should remove the case variants of apple")
+	}
+	expected := []string{"apples"}
+	if results := sst.Search("app"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search('app') after delete: expected %v, got %v", expected, results)
+	}
+}
+
+func TestSearchCasePreferred(t *testing.T) {
+	words := []string{"APPLE", "Apple", "apple", "Application", "apply", "banana"}
+	sst := NewStaticSearchTree(words)
+	
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"app", []string{"apple", "apply", "APPLE", "Apple", "Application"}},
+		{"App", []string{"Apple", "Application", "APPLE", "apple", "apply"}},
+		{"APP", []string{"APPLE", "Apple", "Application", "apple", "apply"}},
+		{"aPP", []string{"APPLE", "Apple", "Application", "apple", "apply"}},
+		{"xyz", []string{}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.SearchCasePreferred(tc.query)
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("SearchCasePreferred('%s'): expected %v, got %v", tc.query, tc.expected, results)
+		}
+	}
+}
+
+func TestNearestByLength(t *testing.T) {
+	words := []string{"car", "card", "care", "cars", "career", "careful", "caretaker"}
+	sst := NewStaticSearchTree(words)
+	
+	testCases := []struct {
+		query     string
+		targetLen int
+		k         int
+		expected  []string
+	}{
+		{"car", 6, 3, []string{"career", "careful", "card"}},
+		{"car", 3, 2, []string{"car", "card"}},
+		{"car", 20, 1, []string{"caretaker"}},
+		{"care", 5, 10, []string{"care", "career", "careful", "caretaker"}},
+		{"car", 4, 0, []string{}},
+		{"dog", 3, 5, []string{}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.NearestByLength(tc.query, tc.targetLen, tc.k)
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("NearestByLength('%s', %d, %d): expected %v, got %v", 
+				tc.query, tc.targetLen, tc.k, tc.expected, results)
+		}
+	}
+}
+
+// Run with -race to detect unsynchronized access
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree([]string{"apple", "app", "banana"}, WithBackend(backend))
+		
+		var wg sync.WaitGroup
+		stop := make(chan struct{})
+		for r := 0; r < 4; r++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					sst.Search("app")
+					sst.SearchWithLimit("a", 2)
+					sst.GetAllPrefixes()
+					sst.Size()
+					sst.Words()
+				}
+			}()
+		}
+		
+		for i := 0; i < 200; i++ {
+			word := fmt.Sprintf("apple%d", i)
+			sst.Insert(word)
+			if i%2 == 0 {
+				sst.Delete(word)
+			}
+		}
+		close(stop)
+		wg.Wait()
+		
+		if results := sst.Search("apple1"); len(results) != 56 {
+			t.Errorf("backend %d: Search('apple1') after concurrent writes: expected 56 results, got %d", 
+				backend, len(results))
+		}
+	}
+}
+
+func TestMultiFieldConcurrentAddRecord(t *testing.T) {
+	m := NewMultiFieldSearchTree()
+	
+	var wg, started sync.WaitGroup
+	stop := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				m.SearchFieldPrefix("name", "item")
+				m.SearchWithFieldPrefix("name", "item", "sku", "x")
+				m.SearchAllFields("item")
+			}
+		}()
+	}
+	
+	started.Wait()
+	for i := 0; i < 1000; i++ {
+		m.AddRecord(RecordID(i), map[string]string{"name": fmt.Sprintf("item%d", i), "sku": fmt.Sprintf("x%d", i)})
+		if i%100 == 0 {
+			m.AddField(fmt.Sprintf("extra%d", i), []string{"item"})
+		}
+	}
+	close(stop)
+	wg.Wait()
+	
+	if ids := m.SearchFieldPrefix("name", "item1"); len(ids) != 111 {
+		t.Errorf("SearchFieldPrefix('name', 'item1') after concurrent writes: expected 111 records, got %d", len(ids))
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	words := []string{"apple", "app", "Application", "banana", "band", "café"}
+	queries := []string{"a", "app", "APPL", "ban", "caf", "xyz", ""}
+	
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		original := NewStaticSearchTree(append([]string(nil), words...), WithBackend(backend), WithTrimSpace(true))
+		data, err := json.Marshal(original)
+		if err != nil {
+			t.Fatalf("backend %d: Marshal returned error: %v", backend, err)
+		}
+		
+		var restored StaticSearchTree
+		if err := json.Unmarshal(data, &restored); err != nil {
+			t.Fatalf("backend %d: Unmarshal returned error: %v", backend, err)
+		}
+		if !reflect.DeepEqual(restored.opts, original.opts) {
+			t.Errorf("backend %d: options not restored: expected %+v, got %+v", backend, original.opts, restored.opts)
+		}
+		if restored.Size() != original.Size() {
+			t.Errorf("backend %d: Size(): expected %d, got %d", backend, original.Size(), restored.Size())
+		}
+		for _, query := range append(queries, " app ") {
+			if results, expected := restored.Search(query), original.Search(query); !reflect.DeepEqual(results, expected) {
+				t.Errorf("backend %d: restored Search('%s'): expected %v, got %v", backend, query, expected, results)
+			}
+		}
+		
+		// Restored trees must still hand out defensive copies
+		results := restored.Search("app")
+		results[0] = "modified"
+		if restored.Search("app")[0] == "modified" {
+			t.Errorf("backend %d: restored Search results should be independent copies", backend)
+		}
+	}
+}
+
+func TestJSONEmptyTree(t *testing.T) {
+	data, err := json.Marshal(NewStaticSearchTree([]string{}))
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	
+	var restored StaticSearchTree
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Unmarshal returned error: %v", err)
+	}
+	if restored.Size() != 0 || len(restored.Search("a")) != 0 {
+		t.Errorf("restored empty tree should have no prefixes, got size %d", restored.Size())
+	}
+	
+	// The restored tree must accept new words
+	restored.Insert("apple")
+	if results := restored.Search("app"); !reflect.DeepEqual(results, []string{"apple"}) {
+		t.Errorf("Search('app') after Insert into restored tree: got %v", results)
+	}
+	
+	if err := json.Unmarshal([]byte("{not json"), &restored); err == nil {
+		t.Error("Unmarshal of invalid JSON should return an error")
+	}
+}
+
+func TestStats(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"app", "apple", "banana", "apple"})
+	stats := sst.Stats()
+	if stats.Prefixes != 11 || stats.Words != 3 {
+		t.Errorf("Stats(): expected 11 prefixes and 3 words, got %+v", stats)
+	}
+	
+	words := []string{"app", "apple", "banana", "band", "bandana", "bank"}
+	estimated := map[Backend]int{}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		stats := NewStaticSearchTree(words, WithBackend(backend)).Stats()
+		
+		// a, ap, app: 2 each; appl, apple: 1 each; b, ba, ban: 4 each;
+		// bana, banan, banana: 1 each; band: 2; banda, bandan, bandana: 1 each;
+		// bank: 1
+		if stats.Prefixes != 16 || stats.Words != 6 {
+			t.Errorf("Stats() [backend %v]: expected 16 prefixes and 6 words, got %+v", backend, stats)
+		}
+		if stats.LongestMatchList != 4 || stats.LongestMatchPrefix != "b" {
+			t.Errorf("Stats() [backend %v]: expected longest match list 4 owned by 'b', got %d owned by '%s'", 
+				backend, stats.LongestMatchList, stats.LongestMatchPrefix)
+		}
+		if expected := 29.0 / 16.0; math.Abs(stats.AverageMatchList-expected) > 1e-9 {
+			t.Errorf("Stats() [backend %v]: expected average match list %v, got %v", backend, expected, stats.AverageMatchList)
+		}
+		if stats.EstimatedBytes <= 0 {
+			t.Errorf("Stats() [backend %v]: expected a positive byte estimate, got %d", backend, stats.EstimatedBytes)
+		}
+		estimated[backend] = stats.EstimatedBytes
+	}
+	if estimated[BackendRadix] >= estimated[BackendPrefixMap] {
+		t.Errorf("Stats(): expected the radix estimate (%d) below the prefix map estimate (%d)", 
+			estimated[BackendRadix], estimated[BackendPrefixMap])
+	}
+}
+
+func TestWatchStats(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple"})
+	interval := 20 * time.Millisecond
+	stats, stop := sst.WatchStats(interval)
+	
+	start := time.Now()
+	first := <-stats
+	if first.Words != 1 {
+		t.Errorf("first emission: expected 1 word, got %+v", first)
+	}
+	
+	sst.InsertAll([]string{"banana", "cherry"})
+	second := <-stats
+	if second.Words != 3 || second.Version <= first.Version {
+		t.Errorf("second emission should reflect the inserts, got %+v after %+v", second, first)
+	}
+	
+	<-stats
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("three emissions took %v, expected at least %v", elapsed, 2*interval)
+	}
+	
+	stop()
+	stop()
+	select {
+	case _, ok := <-stats:
+		if ok {
+			// One emission may already have been in flight
+			if _, ok := <-stats; ok {
+				t.Error("stats channel should be closed after stop")
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stats channel was not closed after stop")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	words := []string{"apple", "app", "Application", "banana", "band", "café"}
+	
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		original := NewStaticSearchTree(append([]string(nil), words...), WithBackend(backend))
+		
+		var buf bytes.Buffer
+		if err := original.Save(&buf); err != nil {
+			t.Fatalf("backend %d: Save returned error: %v", backend, err)
+		}
+		
+		loaded := NewStaticSearchTree(nil)
+		if err := loaded.Load(&buf); err != nil {
+			t.Fatalf("backend %d: Load returned error: %v", backend, err)
+		}
+		if backend == BackendPrefixMap && !reflect.DeepEqual(loaded.tree, original.tree) {
+			t.Errorf("loaded prefix map differs from the original")
+		}
+		for _, query := range []string{"a", "app", "APPL", "ban", "caf", "xyz"} {
+			if results, expected := loaded.Search(query), original.Search(query); !reflect.DeepEqual(results, expected) {
+				t.Errorf("backend %d: loaded Search('%s'): expected %v, got %v", backend, query, expected, results)
+			}
+		}
+	}
+	
+	if err := NewStaticSearchTree(nil).Load(strings.NewReader("garbage")); err == nil {
+		t.Error("Load of invalid data should return an error")
+	}
+}
+
+func TestCanonicalizeQueries(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"Apple", "apple", "banana", "cherry", "Grape"})
+	
+	queries := []string{"apple", "APPLE", "Apple", "aple", "bananna", "chery", "grape", "grap"}
+	expected := []string{"apple", "Apple", "Apple", "Apple", "banana", "cherry", "Grape", "Grape"}
+	if results := sst.CanonicalizeQueries(queries); !reflect.DeepEqual(results, expected) {
+		t.Errorf("CanonicalizeQueries(%v): expected %v, got %v", queries, expected, results)
+	}
+	
+	if results := NewStaticSearchTree(nil).CanonicalizeQueries([]string{"apple"}); !reflect.DeepEqual(results, []string{""}) {
+		t.Errorf("CanonicalizeQueries on empty tree: expected [\"\"], got %q", results)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"apple", "aple", 1},
+		{"café", "cafe", 1},
+	}
+	
+	for _, tc := range testCases {
+		if d := levenshtein(tc.a, tc.b); d != tc.expected {
+			t.Errorf("levenshtein('%s', '%s'): expected %d, got %d", tc.a, tc.b, tc.expected, d)
+		}
+	}
+}
+
+func TestClickOrdering(t *testing.T) {
+	words := []string{"apple", "application", "apply", "apricot"}
+	clicks := "application 42\napricot\t7\n\napply 7\n"
+	
+	sst := NewStaticSearchTree(append([]string(nil), words...), WithClickOrdering())
+	if err := sst.LoadClicks(strings.NewReader(clicks)); err != nil {
+		t.Fatalf("LoadClicks returned error: %v", err)
+	}
+	
+	expected := []string{"application", "apply", "apricot", "apple"}
+	if results := sst.Search("ap"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search('ap') with click ordering: expected %v, got %v", expected, results)
+	}
+	
+	// Without the option the clicks are ignored
+	plain := NewStaticSearchTree(append([]string(nil), words...))
+	if err := plain.LoadClicks(strings.NewReader(clicks)); err != nil {
+		t.Fatalf("LoadClicks returned error: %v", err)
+	}
+	expected = []string{"apple", "application", "apply", "apricot"}
+	if results := plain.Search("ap"); !reflect.DeepEqual(results, expected) {
+		t.Errorf("Search('ap') without click ordering: expected %v, got %v", expected, results)
+	}
+	
+	if err := sst.LoadClicks(strings.NewReader("apple many\n")); err == nil {
+		t.Error("LoadClicks with a non-numeric count should return an error")
+	}
+	if err := sst.LoadClicks(strings.NewReader("apple\n")); err == nil {
+		t.Error("LoadClicks with a missing count should return an error")
+	}
+	
+	// Loading clicks reorders results already in the cache, in every match mode
+	for _, mode := range []MatchMode{MatchPrefix, MatchContains} {
+		sst := NewStaticSearchTree(append([]string(nil), words...), WithClickOrdering(), WithResultCache(16), WithMatchMode(mode))
+		search := func() [][]string {
+			return [][]string{sst.Search("ap"), sst.FuzzySearch("ap", 0), sst.SearchSubstring("ap")}
+		}
+		before := search()
+		if err := sst.LoadClicks(strings.NewReader(clicks)); err != nil {
+			t.Fatalf("LoadClicks returned error: %v", err)
+		}
+		expected := []string{"application", "apply", "apricot", "apple"}
+		for i, results := range search() {
+			if !reflect.DeepEqual(results, expected) {
+				t.Errorf("search %d for 'ap' with MatchMode %d after LoadClicks: expected %v, got %v (before %v)", 
+					i, mode, expected, results, before[i])
+			}
+		}
+	}
+}
+
+func TestCountMatches(t *testing.T) {
+	words := []string{"app", "apple", "Application", "banana", "band"}
+	
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(append([]string(nil), words...), WithBackend(backend))
+		for _, query := range []string{"a", "app", "APPL", "ban", "banana", "xyz", ""} {
+			if count, expected := sst.CountMatches(query), len(sst.Search(query)); count != expected {
+				t.Errorf("backend %d: CountMatches('%s'): expected %d, got %d", backend, query, expected, count)
+			}
+		}
+	}
+	
+	sst := NewStaticSearchTree([]string{"hello world", "hello-world", "help"}, WithWhitespaceInsensitiveDedup(true))
+	if count := sst.CountMatches("hel"); count != 2 {
+		t.Errorf("CountMatches('hel') with dedup: expected 2, got %d", count)
+	}
+	
+	for _, test := range []struct {
+		mode     MatchMode
+		query    string
+		expected int
+	}{
+		{MatchExact, "app", 1},
+		{MatchExact, "appl", 0},
+		{MatchContains, "an", 2},
+		{MatchContains, "pli", 1},
+	} {
+		sst := NewStaticSearchTree(words, WithMatchMode(test.mode))
+		if count := sst.CountMatches(test.query); count != test.expected {
+			t.Errorf("CountMatches('%s') with MatchMode %d: expected %d, got %d", test.query, test.mode, test.expected, count)
+		}
+	}
+}
+
+func BenchmarkCountMatches(b *testing.B) {
+	words := make([]string, 1000)
+	for i := 0; i < 1000; i++ {
+		words[i] = fmt.Sprintf("word%d", i)
+	}
+	sst := NewStaticSearchTree(words)
+	
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sst.CountMatches("word1")
+	}
+}
+
+func TestUniquePrefixes(t *testing.T) {
+	words := []string{"status", "stash", "commit", "checkout", "cherry-pick", "co", "push", "pull", "Über"}
+	sst := NewStaticSearchTree(words)
+	
+	expected := map[string]string{
+		"status":      "stat",
+		"stash":       "stas",
+		"commit":      "com",
+		"checkout":    "chec",
+		"cherry-pick": "cher",
+		"push":        "pus",
+		"pull":        "pul",
+		"Über":        "ü",
+	}
+	if unique := sst.UniquePrefixes(); !reflect.DeepEqual(unique, expected) {
+		t.Errorf("UniquePrefixes(): expected %v, got %v", expected, unique)
+	}
+}
+
+func TestFuzzySearch(t *testing.T) {
+	words := []string{"apple", "application", "Apricot", "banana", "band", "grape"}
+	sst := NewStaticSearchTree(words)
+	
+	testCases := []struct {
+		query    string
+		maxEdits int
+		expected []string
+	}{
+		{"aple", 1, []string{"apple"}},   // missing character
+		{"appple", 1, []string{"apple"}}, // extra character
+		{"bpple", 1, []string{"apple"}},  // substituted character
+		{"APLE", 1, []string{"apple"}},
+		{"aple", 2, []string{"Apricot", "apple", "application"}},
+		{"aprx", 1, []string{"Apricot"}},
+		{"bnd", 1, []string{"band"}},
+		{"zzz", 1, []string{}},
+		{"zzz", 3, []string{"Apricot", "apple", "application", "banana", "band", "grape"}},
+		{"", 2, []string{}},
+		{"ap", -1, []string{}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.FuzzySearch(tc.query, tc.maxEdits)
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("FuzzySearch('%s', %d): expected %v, got %v", tc.query, tc.maxEdits, tc.expected, results)
+		}
+	}
+	
+	for _, query := range []string{"a", "app", "ban", "aple", "", "xyz"} {
+		if results, expected := sst.FuzzySearch(query, 0), sst.Search(query); !reflect.DeepEqual(results, expected) {
+			t.Errorf("FuzzySearch('%s', 0): expected Search result %v, got %v", query, expected, results)
+		}
+	}
+}
+
+func TestSearchSubstring(t *testing.T) {
+	words := []string{"banana", "Bandana", "ananas", "cabana", "apple", "band"}
+	sst := NewStaticSearchTree(words)
+	
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"ana", []string{"Bandana", "ananas", "banana", "cabana"}},
+		{"ANA", []string{"Bandana", "ananas", "banana", "cabana"}},
+		{"anana", []string{"ananas", "banana"}},
+		{"band", []string{"Bandana", "band"}},
+		{"pl", []string{"apple"}},
+		{"xyz", []string{}},
+		{"", []string{}},
+	}
+	
+	for _, tc := range testCases {
+		results := sst.SearchSubstring(tc.query)
+		if !reflect.DeepEqual(results, tc.expected) {
+			t.Errorf("SearchSubstring('%s'): expected %v, got %v", tc.query, tc.expected, results)
+		}
+	}
+}
+
+func TestDumpToMaxDepth(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"cat", "car", "dog"})
+	
+	var buf bytes.Buffer
+	if err := sst.DumpTo(&buf, 2); err != nil {
+		t.Fatalf("DumpTo returned error: %v", err)
+	}
+	expected := "'c' -> [car cat]\n'ca' -> [car cat]\n'd' -> [dog]\n'do' -> [dog]\n"
+	if buf.String() != expected {
+		t.Errorf("DumpTo(w, 2): expected %q, got %q", expected, buf.String())
+	}
+	
+	buf.Reset()
+	if err := sst.DumpTo(&buf, 0); err != nil {
+		t.Fatalf("DumpTo returned error: %v", err)
+	}
+	if lines, prefixes := strings.Count(buf.String(), "\n"), len(sst.GetAllPrefixes()); lines != prefixes {
+		t.Errorf("DumpTo(w, 0): expected %d lines, got %d", prefixes, lines)
+	}
+	if !strings.Contains(buf.String(), "'cat' -> [cat]") {
+		t.Errorf("DumpTo(w, 0) should include the deepest prefixes, got %q", buf.String())
+	}
+}
+
+func TestStaticSearchTreeT(t *testing.T) {
+	type product struct {
+		SKU   string
+		Price int
+	}
+	
+	sst := NewStaticSearchTreeT(map[string]product{
+		"Apple Juice": {"AJ-1", 300},
+		"Apple Pie":   {"AP-2", 550},
+		"Banana":      {"BN-3", 120},
+	})
+	
+	expected := []product{{"AJ-1", 300}, {"AP-2", 550}}
+	if values := sst.SearchValues("apple"); !reflect.DeepEqual(values, expected) {
+		t.Errorf("SearchValues('apple'): expected %v, got %v", expected, values)
+	}
+	if words := sst.Search("apple p"); !reflect.DeepEqual(words, []string{"Apple Pie"}) {
+		t.Errorf("Search('apple p'): expected [Apple Pie], got %v", words)
+	}
+	if values := sst.SearchValues("cherry"); len(values) != 0 {
+		t.Errorf("SearchValues('cherry'): expected no values, got %v", values)
+	}
+	if value, ok := sst.Value("Banana"); !ok || value.SKU != "BN-3" {
+		t.Errorf("Value('Banana'): expected BN-3, got %v (%v)", value, ok)
+	}
+	
+	spaced := NewStaticSearchTreeT(map[string]int{"  apple  pie ": 7}, WithNormalizeWhitespace(true))
+	if values := spaced.SearchValues("app"); !reflect.DeepEqual(values, []int{7}) {
+		t.Errorf("SearchValues('app') with WithNormalizeWhitespace: expected [7], got %v", values)
+	}
+	for _, word := range []string{"  apple  pie ", "apple pie"} {
+		if value, ok := spaced.Value(word); !ok || value != 7 {
+			t.Errorf("Value('%s') with WithNormalizeWhitespace: expected 7, got %v (%v)", word, value, ok)
+		}
+	}
+}
+
+func TestUniquenessDepth(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"status", "stash", "commit", "app", "apple"})
+	
+	tests := []struct {
+		word     string
+		expected int
+	}{
+		{"status", 4},
+		{"stash", 4},
+		{"commit", 1},
+		{"Commit", 1},
+		{"apple", 4},
+		{"app", -1},
+		{"appl", -1},
+		{"stat", -1},
+		{"missing", -1},
+	}
+	
+	for _, test := range tests {
+		if depth := sst.UniquenessDepth(test.word); depth != test.expected {
+			t.Errorf("UniquenessDepth('%s'): expected %d, got %d", test.word, test.expected, depth)
+		}
+	}
+}
+
+func TestKeystrokeSavings(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"status", "stash", "commit", "app", "apple"})
+	
+	// status saves 2, commit saves 5, app saves 0 and missing saves 0
+	log := []string{"status", "commit", "app", "missing"}
+	if savings := sst.KeystrokeSavings(log); savings != 1.75 {
+		t.Errorf("KeystrokeSavings(%v): expected 1.75, got %v", log, savings)
+	}
+	
+	// Prefixes of indexed words are not indexed words themselves
+	log = []string{"appl", "stat"}
+	if savings := sst.KeystrokeSavings(log); savings != 0 {
+		t.Errorf("KeystrokeSavings(%v): expected 0, got %v", log, savings)
+	}
+	if savings := sst.KeystrokeSavings(nil); savings != 0 {
+		t.Errorf("KeystrokeSavings(nil): expected 0, got %v", savings)
+	}
+}
+
+func TestSearchWithBucket(t *testing.T) {
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree([]string{"apple", "application", "banana"}, WithBackend(backend))
+		
+		tests := []struct {
+			query    string
+			expected []string
+			bucket   string
+		}{
+			{"App", []string{"apple", "application"}, "app"},
+			{"applx", []string{"apple", "application"}, "appl"},
+			{"applesauce", []string{"apple"}, "apple"},
+			{"cherry", []string{}, ""},
+		}
+		
+		for _, test := range tests {
+			matches, bucket := sst.SearchWithBucket(test.query)
+			if !reflect.DeepEqual(matches, test.expected) || bucket != test.bucket {
+				t.Errorf("SearchWithBucket('%s') [backend %v]: expected %v from '%s', got %v from '%s'", 
+					test.query, backend, test.expected, test.bucket, matches, bucket)
+			}
+		}
+	}
+}
+
+func TestNewStaticSearchTreeStrict(t *testing.T) {
+	tests := []struct {
+		words    []string
+		expected error
+	}{
+		{[]string{"apple", "banana"}, nil},
+		{[]string{"apple", "apple"}, nil},
+		{[]string{"apple", ""}, ErrEmptyWord},
+		{[]string{"app\tle"}, ErrControlCharacter},
+		{[]string{"banana", "line\nbreak"}, ErrControlCharacter},
+	}
+	
+	for _, test := range tests {
+		sst, err := NewStaticSearchTreeStrict(test.words)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("NewStaticSearchTreeStrict(%q): expected error %v, got %v", test.words, test.expected, err)
+		}
+		if (err == nil) != (sst != nil) {
+			t.Errorf("NewStaticSearchTreeStrict(%q): expected a tree exactly when there is no error", test.words)
+		}
+	}
+	
+	// The lenient constructor still accepts the same input
+	if sst := NewStaticSearchTree([]string{"apple", ""}); len(sst.Search("app")) != 1 {
+		t.Errorf("NewStaticSearchTree: expected empty words to be tolerated")
+	}
+}
+
+func TestExactMatch(t *testing.T) {
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree([]string{"app", "apple", "Banana"}, WithBackend(backend))
+		
+		tests := []struct {
+			word   string
+			exact  bool
+			prefix bool
+		}{
+			{"app", true, true},
+			{"APP", true, true},
+			{"ap", false, true},
+			{"apple", true, true},
+			{"appl", false, true},
+			{"banana", true, true},
+			{"bananas", false, false},
+		}
+		
+		for _, test := range tests {
+			if exact := sst.ExactMatch(test.word); exact != test.exact {
+				t.Errorf("ExactMatch('%s') [backend %v]: expected %v, got %v", test.word, backend, test.exact, exact)
+			}
+			if prefix := sst.PrefixExists(test.word); prefix != test.prefix {
+				t.Errorf("PrefixExists('%s') [backend %v]: expected %v, got %v", test.word, backend, test.prefix, prefix)
+			}
+		}
+	}
+}
+
+func TestPrefixEfficiency(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "apply", "apricot", "banana"})
+	scores := sst.PrefixEfficiency()
+	
+	tests := []struct {
+		prefix   string
+		expected float64
+	}{
+		{"a", math.Log2(4.0 / 3.0)},
+		{"b", 2},
+		{"appl", 1.0 / 4.0},
+		{"apricot", 2.0 / 7.0},
+	}
+	
+	for _, test := range tests {
+		if score := scores[test.prefix]; math.Abs(score-test.expected) > 1e-9 {
+			t.Errorf("PrefixEfficiency()['%s']: expected %v, got %v", test.prefix, test.expected, score)
+		}
+	}
+	if prefixes := len(sst.GetAllPrefixes()); len(scores) != prefixes {
+		t.Errorf("PrefixEfficiency(): expected %d scores, got %d", prefixes, len(scores))
+	}
+	
+	// A short prefix matching many words narrows less than one matching a single word
+	if scores["a"] >= scores["b"] {
+		t.Errorf("PrefixEfficiency(): expected 'a' (%v) to score below 'b' (%v)", scores["a"], scores["b"])
+	}
+}
+
+func TestLongestMatchingPrefix(t *testing.T) {
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree([]string{"a", "ap", "app", "/api/v1"}, WithBackend(backend))
+		
+		tests := []struct {
+			query    string
+			expected string
+			found    bool
+		}{
+			{"apple", "app", true},
+			{"APX", "ap", true},
+			{"a", "a", true},
+			{"/api/v1/users", "/api/v1", true},
+			{"/apx", "/ap", true},
+			{"banana", "", false},
+			{"", "", false},
+		}
+		
+		for _, test := range tests {
+			prefix, found := sst.LongestMatchingPrefix(test.query)
+			if prefix != test.expected || found != test.found {
+				t.Errorf("LongestMatchingPrefix('%s') [backend %v]: expected ('%s', %v), got ('%s', %v)", 
+					test.query, backend, test.expected, test.found, prefix, found)
+			}
+		}
+	}
+}
+
+func TestSearchSortedStream(t *testing.T) {
+	words := []string{"apricot", "Apple", "application", "app", "apply", "banana"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend))
+		
+		var streamed []string
+		sst.SearchSortedStream("ap", func(word string) bool {
+			streamed = append(streamed, word)
+			return true
+		})
+		expected := []string{"app", "Apple", "application", "apply", "apricot"}
+		if !reflect.DeepEqual(streamed, expected) {
+			t.Errorf("SearchSortedStream('ap') [backend %v]: expected %v, got %v", backend, expected, streamed)
+		}
+		
+		// Returning false stops the stream
+		streamed = nil
+		sst.SearchSortedStream("ap", func(word string) bool {
+			streamed = append(streamed, word)
+			return len(streamed) < 2
+		})
+		if !reflect.DeepEqual(streamed, expected[:2]) {
+			t.Errorf("SearchSortedStream('ap') with early stop [backend %v]: expected %v, got %v", 
+				backend, expected[:2], streamed)
+		}
+		
+		sst.SearchSortedStream("cherry", func(word string) bool {
+			t.Errorf("SearchSortedStream('cherry') [backend %v]: unexpected match %s", backend, word)
+			return true
+		})
+	}
+}
+
+func TestResolveUnique(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"commit", "checkout", "cherry-pick", "status"})
+	
+	tests := []struct {
+		query     string
+		expected  string
+		ambiguous bool
+		err       error
+	}{
+		{"co", "commit", false, nil},
+		{"STAT", "status", false, nil},
+		{"ch", "", true, ErrAmbiguousPrefix},
+		{"c", "", true, ErrAmbiguousPrefix},
+		{"push", "", false, ErrNoMatch},
+	}
+	
+	for _, test := range tests {
+		if ambiguous := sst.IsAmbiguous(test.query); ambiguous != test.ambiguous {
+			t.Errorf("IsAmbiguous('%s'): expected %v, got %v", test.query, test.ambiguous, ambiguous)
+		}
+		word, err := sst.ResolveUnique(test.query)
+		if word != test.expected || !errors.Is(err, test.err) {
+			t.Errorf("ResolveUnique('%s'): expected ('%s', %v), got ('%s', %v)", 
+				test.query, test.expected, test.err, word, err)
+		}
+	}
+	
+	modes := []struct {
+		mode      MatchMode
+		query     string
+		ambiguous bool
+	}{
+		{MatchExact, "commit", false},
+		{MatchExact, "co", false},
+		{MatchContains, "t", true},
+		{MatchContains, "eck", false},
+	}
+	for _, test := range modes {
+		sst := NewStaticSearchTree([]string{"commit", "checkout", "cherry-pick", "status"}, WithMatchMode(test.mode))
+		if ambiguous := sst.IsAmbiguous(test.query); ambiguous != test.ambiguous {
+			t.Errorf("IsAmbiguous('%s') with MatchMode %d: expected %v, got %v", test.query, test.mode, test.ambiguous, ambiguous)
+		}
+		if _, err := sst.ResolveUnique(test.query); errors.Is(err, ErrAmbiguousPrefix) != test.ambiguous {
+			t.Errorf("ResolveUnique('%s') with MatchMode %d: expected ambiguous %v, got %v", test.query, test.mode, test.ambiguous, err)
+		}
+	}
+}
+
+func TestCaseSensitivity(t *testing.T) {
+	words := []string{"API", "api", "Apple", "apiary"}
+	insensitive := NewStaticSearchTree(words)
+	sensitive := NewStaticSearchTreeWithOptions(words, Options{CaseSensitive: true})
+	
+	tests := []struct {
+		query       string
+		insensitive []string
+		sensitive   []string
+	}{
+		{"api", []string{"API", "api", "apiary"}, []string{"api", "apiary"}},
+		{"API", []string{"API", "api", "apiary"}, []string{"API"}},
+		{"A", []string{"API", "Apple", "api", "apiary"}, []string{"API", "Apple"}},
+		{"apple", []string{"Apple"}, []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := insensitive.Search(test.query); !reflect.DeepEqual(result, test.insensitive) {
+			t.Errorf("Search('%s') case-insensitive: expected %v, got %v", test.query, test.insensitive, result)
+		}
+		if result := sensitive.Search(test.query); !reflect.DeepEqual(result, test.sensitive) {
+			t.Errorf("Search('%s') case-sensitive: expected %v, got %v", test.query, test.sensitive, result)
+		}
+	}
+	
+	if !NewStaticSearchTree(words, WithCaseSensitive(true)).ExactMatch("API") {
+		t.Errorf("ExactMatch('API') with WithCaseSensitive: expected true")
+	}
+	if NewStaticSearchTree(words, WithCaseSensitive(true)).ExactMatch("APPLE") {
+		t.Errorf("ExactMatch('APPLE') with WithCaseSensitive: expected false")
+	}
+}
+
+func TestUnicodeFolding(t *testing.T) {
+	composed := "caf\u00e9"
+	decomposed := "cafe\u0301"
+	
+	tests := []struct {
+		words    []string
+		query    string
+		expected []string
+	}{
+		{[]string{composed}, decomposed, []string{composed}},
+		{[]string{decomposed}, composed, []string{decomposed}},
+		{[]string{"Stra\u00dfe"}, "strasse", []string{"Stra\u00dfe"}},
+		{[]string{"Strasse"}, "STRA\u00dfE", []string{"Strasse"}},
+		{[]string{"\u039f\u03b4\u03cc\u03c2"}, "\u03bf\u03b4\u03bf\u0301", []string{"\u039f\u03b4\u03cc\u03c2"}},
+	}
+	
+	for _, test := range tests {
+		sst := NewStaticSearchTree(test.words, WithUnicodeFolding(true))
+		if result := sst.Search(test.query); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Search('%s') with WithUnicodeFolding: expected %v, got %v", test.query, test.expected, result)
+		}
+	}
+	
+	// Without the option the two forms of the accent stay distinct
+	if result := NewStaticSearchTree([]string{composed}).Search(decomposed); len(result) != 0 {
+		t.Errorf("Search('%s') without folding: expected no matches, got %v", decomposed, result)
+	}
+}
+
+func TestNewStaticSearchTreeFromReaders(t *testing.T) {
+	readers := []io.Reader{
+		strings.NewReader("apple\nbanana\n\napricot\n"),
+		strings.NewReader("banana\navocado\napple\n"),
+	}
+	sst, err := NewStaticSearchTreeFromReaders(readers)
+	if err != nil {
+		t.Fatalf("NewStaticSearchTreeFromReaders: unexpected error %v", err)
+	}
+	
+	expected := []string{"apple", "apricot", "avocado", "banana"}
+	if words := sst.Words(); !reflect.DeepEqual(words, expected) {
+		t.Errorf("NewStaticSearchTreeFromReaders: expected words %v, got %v", expected, words)
+	}
+	if result := sst.Search("b"); !reflect.DeepEqual(result, []string{"banana"}) {
+		t.Errorf("Search('b'): expected [banana], got %v", result)
+	}
+	
+	failing := errors.New("disk failure")
+	readers = []io.Reader{strings.NewReader("apple\n"), iotest.ErrReader(failing)}
+	if _, err := NewStaticSearchTreeFromReaders(readers); !errors.Is(err, failing) || !strings.Contains(err.Error(), "reader 1") {
+		t.Errorf("NewStaticSearchTreeFromReaders with failing reader: expected reader 1 error, got %v", err)
+	}
+}
+
+func TestTopQueries(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "banana", "cherry"})
+	log := []string{"app", "ban", "App", "che", "ban", "zzz", "APP", "che", "b"}
+	
+	tests := []struct {
+		n        int
+		expected []string
+	}{
+		{1, []string{"app"}},
+		{3, []string{"app", "ban", "che"}},
+		{4, []string{"app", "ban", "che", "b"}},
+		{10, []string{"app", "ban", "che", "b", "zzz"}},
+		{0, []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.TopQueries(log, test.n); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("TopQueries(log, %d): expected %v, got %v", test.n, test.expected, result)
+		}
+	}
+}
+
+func TestSearchIter(t *testing.T) {
+	words := []string{"Apple", "app", "application", "apply", "banana"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend))
+		
+		var all []string
+		for word := range sst.SearchIter("APP") {
+			all = append(all, word)
+		}
+		if expected := sst.Search("APP"); !reflect.DeepEqual(all, expected) {
+			t.Errorf("SearchIter('APP') [backend %v]: expected %v, got %v", backend, expected, all)
+		}
+		
+		var first []string
+		for word := range sst.SearchIter("app") {
+			first = append(first, word)
+			if len(first) == 2 {
+				break
+			}
+		}
+		if expected := sst.Search("app")[:2]; !reflect.DeepEqual(first, expected) {
+			t.Errorf("SearchIter('app') first 2 [backend %v]: expected %v, got %v", backend, expected, first)
+		}
+		
+		for word := range sst.SearchIter("cherry") {
+			t.Errorf("SearchIter('cherry') [backend %v]: unexpected match %s", backend, word)
+		}
+	}
+}
+
+func TestPathOf(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"romane", "romanus", "Romulus", "rubens", "ruber"}, WithBackend(BackendRadix))
+	
+	tests := []struct {
+		word     string
+		expected []string
+	}{
+		{"romane", []string{"r", "om", "an", "e"}},
+		{"Romulus", []string{"r", "om", "ulus"}},
+		{"ruber", []string{"r", "ube", "r"}},
+		{"rom", nil},
+		{"remus", nil},
+	}
+	
+	for _, test := range tests {
+		path := sst.PathOf(test.word)
+		if !reflect.DeepEqual(path, test.expected) {
+			t.Errorf("PathOf('%s'): expected %v, got %v", test.word, test.expected, path)
+		}
+		if path != nil && strings.Join(path, "") != strings.ToLower(test.word) {
+			t.Errorf("PathOf('%s'): labels %v do not spell the word", test.word, path)
+		}
+	}
+	
+	if path := NewStaticSearchTree([]string{"romane"}, WithBackend(BackendPrefixMap)).PathOf("romane"); path != nil {
+		t.Errorf("PathOf('romane') with prefix map backend: expected nil, got %v", path)
+	}
+}
+
+func TestSearchContext(t *testing.T) {
+	words := randomWords(3000)
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend))
+		
+		result, err := sst.SearchContext(context.Background(), "a")
+		if err != nil || !reflect.DeepEqual(result, sst.Search("a")) {
+			t.Errorf("SearchContext('a') [backend %v]: expected Search results, got %d words and %v", 
+				backend, len(result), err)
+		}
+		
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if result, err := sst.SearchContext(ctx, "a"); !errors.Is(err, context.Canceled) || result != nil {
+			t.Errorf("SearchContext('a') cancelled [backend %v]: expected context.Canceled, got %d words and %v", 
+				backend, len(result), err)
+		}
+	}
+}
+
+// cancelAfterContext reports context.Canceled once Err has been called checks times
+type cancelAfterContext struct {
+	context.Context
+	checks int
+}
+
+func (c *cancelAfterContext) Err() error {
+	if c.checks <= 0 {
+		return context.Canceled
+	}
+	c.checks--
+	return nil
+}
+
+func TestSearchContextCancelDuringScan(t *testing.T) {
+	words := randomWords(50000)
+	
+	// Two checks pass, the one up front and the first during the scan, so the
+	// search is cancelled part way through the words
+	contains := NewStaticSearchTree(words, WithMatchMode(MatchContains))
+	ctx := &cancelAfterContext{Context: context.Background(), checks: 2}
+	if result, err := contains.SearchContext(ctx, "a"); !errors.Is(err, context.Canceled) || result != nil {
+		t.Errorf("SearchContext('a') with MatchContains cancelled during the scan: expected context.Canceled, got %d words and %v", 
+			len(result), err)
+	}
+	if result, err := contains.SearchContext(context.Background(), "ab"); err != nil || !reflect.DeepEqual(result, contains.Search("ab")) {
+		t.Errorf("SearchContext('ab') with MatchContains: expected Search results, got %d words and %v", len(result), err)
+	}
+	
+	sst := NewStaticSearchTree(words)
+	ctx = &cancelAfterContext{Context: context.Background(), checks: 2}
+	if result, err := sst.FuzzySearchContext(ctx, "abc", 1); !errors.Is(err, context.Canceled) || result != nil {
+		t.Errorf("FuzzySearchContext('abc', 1) cancelled during the scan: expected context.Canceled, got %d words and %v", 
+			len(result), err)
+	}
+	if result, err := sst.FuzzySearchContext(context.Background(), "abc", 1); err != nil || !reflect.DeepEqual(result, sst.FuzzySearch("abc", 1)) {
+		t.Errorf("FuzzySearchContext('abc', 1): expected FuzzySearch results, got %d words and %v", len(result), err)
+	}
+}
+
+func TestSession(t *testing.T) {
+	words := []string{"apple", "Application", "apply", "apricot", "banana", "band"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend))
+		session := sst.NewSession()
+		
+		for _, ch := range "appLx" {
+			result := session.Type(ch)
+			if expected := sst.Search(session.Query()); !reflect.DeepEqual(result, expected) {
+				t.Errorf("Session.Type('%c') [backend %v]: expected %v, got %v", ch, backend, expected, result)
+			}
+		}
+		if session.searches != 1 {
+			t.Errorf("Session [backend %v]: expected 1 full search, got %d", backend, session.searches)
+		}
+		
+		// Changing the tree forces the next keystroke to search again
+		session.Reset()
+		session.Type('b')
+		sst.Insert("banner")
+		if result := session.Type('a'); !reflect.DeepEqual(result, []string{"banana", "band", "banner"}) {
+			t.Errorf("Session.Type('a') after Insert [backend %v]: expected [banana band banner], got %v", backend, result)
+		}
+		if session.searches != 3 {
+			t.Errorf("Session after Insert [backend %v]: expected 3 full searches, got %d", backend, session.searches)
+		}
+	}
+}
+
+func TestSearchByWeight(t *testing.T) {
+	sst := NewStaticSearchTreeWithWeights(map[string]int{
+		"apple":       5,
+		"application": 20,
+		"apply":       5,
+		"apricot":     0,
+		"banana":      100,
+	})
+	sst.Insert("app")
+	
+	tests := []struct {
+		query    string
+		limit    int
+		expected []string
+	}{
+		{"ap", 10, []string{"application", "apple", "apply", "app", "apricot"}},
+		{"ap", 2, []string{"application", "apple"}},
+		{"b", 10, []string{"banana"}},
+		{"c", 10, []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.SearchByWeight(test.query, test.limit); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("SearchByWeight('%s', %d): expected %v, got %v", test.query, test.limit, test.expected, result)
+		}
+	}
+	
+	sst.SetWeight("apricot", 50)
+	sst.SetWeight("app", 6)
+	expected := []string{"apricot", "application", "app", "apple", "apply"}
+	if result := sst.SearchByWeight("ap", 10); !reflect.DeepEqual(result, expected) {
+		t.Errorf("SearchByWeight('ap', 10) after SetWeight: expected %v, got %v", expected, result)
+	}
+}
+
+func TestSearchHighlighted(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "Application", "APPLY", "Ärger", "ärgerlich", "banana"})
+	
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"app", []string{"<b>APP</b>LY", "<b>App</b>lication", "<b>app</b>le"}},
+		{"APPLI", []string{"<b>Appli</b>cation"}},
+		{"apply", []string{"<b>APPLY</b>"}},
+		{"är", []string{"<b>Är</b>ger", "<b>är</b>gerlich"}},
+		{"Ärg", []string{"<b>Ärg</b>er", "<b>ärg</b>erlich"}},
+		{"cherry", []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.SearchHighlighted(test.query, "<b>", "</b>"); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("SearchHighlighted('%s'): expected %v, got %v", test.query, test.expected, result)
+		}
+	}
+	
+	folded := NewStaticSearchTree([]string{"Straße"}, WithUnicodeFolding(true))
+	if result := folded.SearchHighlighted("strass", "[", "]"); !reflect.DeepEqual(result, []string{"[Straß]e"}) {
+		t.Errorf("SearchHighlighted('strass') with WithUnicodeFolding: expected [[Straß]e], got %v", result)
+	}
+}
+
+func TestClearAndRebuild(t *testing.T) {
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree([]string{"apple", "apricot", "banana"}, WithBackend(backend))
+		snap := sst.Snapshot()
+		
+		sst.Rebuild([]string{"cherry", "citrus", "date"})
+		if result := sst.Search("ap"); len(result) != 0 {
+			t.Errorf("Search('ap') after Rebuild [backend %v]: expected no matches, got %v", backend, result)
+		}
+		if result := sst.Search("c"); !reflect.DeepEqual(result, []string{"cherry", "citrus"}) {
+			t.Errorf("Search('c') after Rebuild [backend %v]: expected [cherry citrus], got %v", backend, result)
+		}
+		if words := sst.Words(); !reflect.DeepEqual(words, []string{"cherry", "citrus", "date"}) {
+			t.Errorf("Words() after Rebuild [backend %v]: expected [cherry citrus date], got %v", backend, words)
+		}
+		if result := snap.Search("ap"); !reflect.DeepEqual(result, []string{"apple", "apricot"}) {
+			t.Errorf("Snapshot Search('ap') after Rebuild [backend %v]: expected [apple apricot], got %v", backend, result)
+		}
+		
+		version := sst.Stats().Version
+		sst.Clear()
+		if size, words := sst.Size(), sst.Words(); size != 0 || len(words) != 0 {
+			t.Errorf("Clear() [backend %v]: expected an empty tree, got %d prefixes and words %v", backend, size, words)
+		}
+		if sst.Stats().Version == version {
+			t.Errorf("Clear() [backend %v]: expected the version to change", backend)
+		}
+		sst.Insert("elder")
+		if result := sst.Search("e"); !reflect.DeepEqual(result, []string{"elder"}) {
+			t.Errorf("Search('e') after Clear and Insert [backend %v]: expected [elder], got %v", backend, result)
+		}
+	}
+}
+
+func TestSearchAny(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "application", "apricot", "banana", "band", "cherry"})
+	
+	tests := []struct {
+		queries  []string
+		expected []string
+	}{
+		{[]string{"app", "ban"}, []string{"apple", "application", "banana", "band"}},
+		{[]string{"ap", "app", "APPL"}, []string{"apple", "application", "apricot"}},
+		{[]string{"band", "xyz", "ch"}, []string{"band", "cherry"}},
+		{[]string{"xyz"}, []string{}},
+		{nil, []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.SearchAny(test.queries...); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("SearchAny(%q): expected %v, got %v", test.queries, test.expected, result)
+		}
+	}
+}
+
+func TestSearchAll(t *testing.T) {
+	sst := NewStaticSearchTree([]string{
+		"report_q3_final",
+		"Report_Q3_Draft",
+		"report_q4_final",
+		"REPORT_Q3_FINAL_v2",
+		"summary_q3_final",
+	})
+	
+	tests := []struct {
+		prefix     string
+		substrings []string
+		expected   []string
+	}{
+		{"report_", []string{"q3", "final"}, []string{"REPORT_Q3_FINAL_v2", "report_q3_final"}},
+		{"report_", []string{"Q3"}, []string{"REPORT_Q3_FINAL_v2", "Report_Q3_Draft", "report_q3_final"}},
+		{"report_", []string{"final", "q4"}, []string{"report_q4_final"}},
+		{"report_", nil, []string{"REPORT_Q3_FINAL_v2", "Report_Q3_Draft", "report_q3_final", "report_q4_final"}},
+		{"summary", []string{"draft"}, []string{}},
+		{"memo", []string{"q3"}, []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.SearchAll(test.prefix, test.substrings...); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("SearchAll('%s', %q): expected %v, got %v", test.prefix, test.substrings, test.expected, result)
+		}
+	}
+}
+
+func TestMaxPrefixLength(t *testing.T) {
+	long := strings.Repeat("abcdefghij", 1000)
+	words := []string{long, "abcdefghXYZ", "abcdefghijklm", "apple"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend), WithMaxPrefixLength(8))
+		
+		tests := []struct {
+			query    string
+			expected []string
+		}{
+			{"abcdefgh", []string{"abcdefghXYZ", long, "abcdefghijklm"}},
+			{"abcdefghij", []string{long, "abcdefghijklm"}},
+			{"ABCDEFGHIJKLMN", []string{}},
+			{long[:2000], []string{long}},
+			{"abcdefghx", []string{"abcdefghXYZ"}},
+			{"apple", []string{"apple"}},
+		}
+		
+		for _, test := range tests {
+			if result := sst.Search(test.query); !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Search('%.20s') [backend %v]: expected %d matches, got %d", 
+					test.query, backend, len(test.expected), len(result))
+			}
+			if count := sst.CountMatches(test.query); count != len(test.expected) {
+				t.Errorf("CountMatches('%.20s') [backend %v]: expected %d, got %d", test.query, backend, len(test.expected), count)
+			}
+		}
+		
+		if !sst.Delete("abcdefghXYZ") || len(sst.Search("abcdefghX")) != 0 {
+			t.Errorf("Delete('abcdefghXYZ') [backend %v]: expected the word to be removed", backend)
+		}
+		sst.Insert("abcdefghijQQ")
+		if result := sst.Search("abcdefghijq"); !reflect.DeepEqual(result, []string{"abcdefghijQQ"}) {
+			t.Errorf("Search('abcdefghijq') after Insert [backend %v]: expected [abcdefghijQQ], got %v", backend, result)
+		}
+	}
+	
+	// The prefix map stores at most 8 prefixes per word: a..abcdefgh plus ap..apple
+	if size := NewStaticSearchTree(words, WithBackend(BackendPrefixMap), WithMaxPrefixLength(8)).Size(); size != 12 {
+		t.Errorf("Size() with WithMaxPrefixLength(8): expected 12, got %d", size)
+	}
+	
+	path := filepath.Join(t.TempDir(), "capped.idx")
+	if err := NewStaticSearchTree(words, WithMaxPrefixLength(8)).WriteDiskIndex(path); err != nil {
+		t.Fatalf("WriteDiskIndex: %v", err)
+	}
+	dst, err := OpenDiskIndex(path)
+	if err != nil {
+		t.Fatalf("OpenDiskIndex: %v", err)
+	}
+	defer dst.Close()
+	if result := dst.Search("abcdefghij"); !reflect.DeepEqual(result, []string{long, "abcdefghijklm"}) {
+		t.Errorf("DiskSearchTree.Search('abcdefghij') with WithMaxPrefixLength(8): expected 2 matches, got %d", len(result))
+	}
+}
+
+func TestSearchPaginated(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"a1", "a2", "a3", "a4", "a5", "b1"})
+	
+	tests := []struct {
+		offset   int
+		limit    int
+		expected []string
+	}{
+		{0, 2, []string{"a1", "a2"}},
+		{2, 2, []string{"a3", "a4"}},
+		{4, 2, []string{"a5"}},
+		{5, 2, []string{}},
+		{9, 2, []string{}},
+		{-3, 2, []string{"a1", "a2"}},
+		{1, 0, []string{}},
+		{1, 10, []string{"a2", "a3", "a4", "a5"}},
+	}
+	
+	for _, test := range tests {
+		page, total := sst.SearchPaginated("a", test.offset, test.limit)
+		if !reflect.DeepEqual(page, test.expected) || total != 5 {
+			t.Errorf("SearchPaginated('a', %d, %d): expected %v of 5, got %v of %d", 
+				test.offset, test.limit, test.expected, page, total)
+		}
+	}
+	
+	if page, total := sst.SearchPaginated("c", 0, 10); len(page) != 0 || total != 0 {
+		t.Errorf("SearchPaginated('c', 0, 10): expected an empty page of 0, got %v of %d", page, total)
+	}
+}
+
+func TestDeleteExactCase(t *testing.T) {
+	words := []string{"Apple", "apple", "APPLE", "apricot"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend), WithDeleteExactCase(true))
+		
+		if result := sst.Search("app"); !reflect.DeepEqual(result, []string{"APPLE", "Apple", "apple"}) {
+			t.Errorf("Search('app') [backend %v]: expected every casing, got %v", backend, result)
+		}
+		
+		// Casings share their prefixes, so Size matches a single-casing tree
+		// while Stats counts each casing as a word
+		if size, expected := sst.Size(), NewStaticSearchTree([]string{"apple", "apricot"}, WithBackend(backend)).Size(); size != expected {
+			t.Errorf("Size() [backend %v]: expected %d, got %d", backend, expected, size)
+		}
+		if words := sst.Stats().Words; words != 4 {
+			t.Errorf("Stats().Words [backend %v]: expected 4, got %d", backend, words)
+		}
+		
+		if !sst.Delete("apple") {
+			t.Errorf("Delete('apple') [backend %v]: expected true", backend)
+		}
+		if result := sst.Search("app"); !reflect.DeepEqual(result, []string{"APPLE", "Apple"}) {
+			t.Errorf("Search('app') after Delete [backend %v]: expected [APPLE Apple], got %v", backend, result)
+		}
+		if sst.Delete("aPPle") {
+			t.Errorf("Delete('aPPle') [backend %v]: expected false for a casing that was never indexed", backend)
+		}
+		
+		// Without the option the casings are still distinct results, but every
+		// casing goes at once
+		lenient := NewStaticSearchTree(words, WithBackend(backend))
+		if result := lenient.Search("app"); !reflect.DeepEqual(result, []string{"APPLE", "Apple", "apple"}) {
+			t.Errorf("Search('app') without the option [backend %v]: expected every casing, got %v", backend, result)
+		}
+		lenient.Delete("apple")
+		if result := lenient.Search("app"); len(result) != 0 {
+			t.Errorf("Search('app') after Delete without the option [backend %v]: expected no matches, got %v", backend, result)
+		}
+	}
+}
+
+func TestBuildEquivalence(t *testing.T) {
+	words := randomWords(500)
+	for i := 0; i < len(words); i += 7 {
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	words = append(words, words[:40]...)
+	words = append(words, "", "Straße", "strasse", "ümlaut", "Ümlaut")
+	
+	tests := []struct {
+		name    string
+		opts    Options
+		initial []string
+	}{
+		{"default", Options{}, nil},
+		{"capped", Options{MaxPrefixLength: 3}, nil},
+		{"folded", Options{RepeatFolding: true, UnicodeFolding: true}, nil},
+		{"non-empty", Options{}, []string{"zebra", "apple", "Apple"}},
+	}
+	
+	for _, test := range tests {
+		test.opts.Backend = BackendPrefixMap
+		fast, reference := newTree(test.opts), newTree(test.opts)
+		if test.initial != nil {
+			fast.build(append([]string(nil), test.initial...))
+			buildQuadratic(reference, append([]string(nil), test.initial...))
+		}
+		fast.build(append([]string(nil), words...))
+		buildQuadratic(reference, append([]string(nil), words...))
+		if !reflect.DeepEqual(fast.tree, reference.tree) {
+			t.Errorf("build (%s): prefix map differs from the quadratic reference", test.name)
+		}
+	}
+}
+
+func TestSearchRegex(t *testing.T) {
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree([]string{"apple", "Apple", "ape", "grape", "apricot", "apple"}, WithBackend(backend))
+		
+		tests := []struct {
+			pattern  string
+			expected []string
+		}{
+			{"^ap.*e$", []string{"ape", "apple"}},
+			{"(?i)^ap.*e$", []string{"Apple", "ape", "apple"}},
+			{"ape", []string{"ape", "grape"}},
+			{"^pl", []string{}},
+		}
+		
+		for _, test := range tests {
+			result, err := sst.SearchRegex(test.pattern)
+			if err != nil || !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("SearchRegex('%s') [backend %v]: expected %v, got %v (%v)", test.pattern, backend, test.expected, result, err)
+			}
+		}
+		
+		if result, err := sst.SearchRegex("ap[("); err == nil {
+			t.Errorf("SearchRegex('ap[('): expected a compile error, got %v", result)
+		}
+	}
+}
+
+func TestGetCompletions(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"app", "Apple", "application", "Ärger", "ärgerlich", "日本語", "日本"})
+	
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"app", []string{"le", "", "lication"}},
+		{"APPLE", []string{""}},
+		{"är", []string{"ger", "gerlich"}},
+		{"ÄRGER", []string{"", "lich"}},
+		{"日", []string{"本", "本語"}},
+		{"日本", []string{"", "語"}},
+		{"cherry", []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.GetCompletions(test.query); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("GetCompletions('%s'): expected %q, got %q", test.query, test.expected, result)
+		}
+	}
+}
+
+func TestResultCache(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "application", "banana", "bandana"}, WithResultCache(2))
+	
+	first := sst.FuzzySearch("aple", 1)
+	second := sst.FuzzySearch("APLE", 1)
+	if !reflect.DeepEqual(first, second) || len(first) == 0 {
+		t.Errorf("FuzzySearch('aple', 1): expected identical cached results, got %v and %v", first, second)
+	}
+	if hits, misses := sst.CacheStats(); hits != 1 || misses != 1 {
+		t.Errorf("CacheStats() after repeated FuzzySearch: expected 1 hit and 1 miss, got %d and %d", hits, misses)
+	}
+	
+	// Modifying a returned slice must not corrupt the cache
+	second[0] = "corrupted"
+	if result := sst.FuzzySearch("aple", 1); !reflect.DeepEqual(result, first) {
+		t.Errorf("FuzzySearch('aple', 1) after modifying a result: expected %v, got %v", first, result)
+	}
+	
+	// Filling the two entries evicts the least recently used fuzzy result
+	sst.SearchSubstring("an")
+	sst.SearchSubstring("ana")
+	_, misses := sst.CacheStats()
+	sst.FuzzySearch("aple", 1)
+	if _, after := sst.CacheStats(); after != misses+1 {
+		t.Errorf("FuzzySearch('aple', 1) after eviction: expected a miss, got %d misses (was %d)", after, misses)
+	}
+	
+	// Changes to the tree invalidate cached results
+	sst.Insert("cabana")
+	if result := sst.SearchSubstring("ana"); !reflect.DeepEqual(result, []string{"banana", "bandana", "cabana"}) {
+		t.Errorf("SearchSubstring('ana') after Insert: expected [banana bandana cabana], got %v", result)
+	}
+	
+	if hits, misses := NewStaticSearchTree([]string{"apple"}).CacheStats(); hits != 0 || misses != 0 {
+		t.Errorf("CacheStats() without a cache: expected zeros, got %d and %d", hits, misses)
+	}
+}
+
+func TestTokenization(t *testing.T) {
+	words := []string{"New York City", "York", "Salt Lake City,Utah", "Ann Annie", "rock-n-roll"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend), WithTokenization(",-"))
+		
+		tests := []struct {
+			query    string
+			expected []string
+		}{
+			{"york", []string{"New York City", "York"}},
+			{"new y", []string{"New York City"}},
+			{"city", []string{"New York City", "Salt Lake City,Utah"}},
+			{"utah", []string{"Salt Lake City,Utah"}},
+			{"ann", []string{"Ann Annie"}},
+			{"roll", []string{"rock-n-roll"}},
+			{"n", []string{"New York City", "rock-n-roll"}},
+			{"ity", []string{}},
+		}
+		
+		for _, test := range tests {
+			if result := sst.Search(test.query); !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Search('%s') [backend %v]: expected %v, got %v", test.query, backend, test.expected, result)
+			}
+			if count := sst.CountMatches(test.query); count != len(test.expected) {
+				t.Errorf("CountMatches('%s') [backend %v]: expected %d, got %d", test.query, backend, len(test.expected), count)
+			}
+		}
+		if indexed := sst.Words(); len(indexed) != len(words) {
+			t.Errorf("Words() [backend %v]: expected %d words, got %v", backend, len(words), indexed)
+		}
+		
+		if !sst.Delete("new york city") {
+			t.Errorf("Delete('new york city') [backend %v]: expected true", backend)
+		}
+		if result := sst.Search("york"); !reflect.DeepEqual(result, []string{"York"}) {
+			t.Errorf("Search('york') after Delete [backend %v]: expected [York], got %v", backend, result)
+		}
+		if result := sst.Search("city"); !reflect.DeepEqual(result, []string{"Salt Lake City,Utah"}) {
+			t.Errorf("Search('city') after Delete [backend %v]: expected [Salt Lake City,Utah], got %v", backend, result)
+		}
+	}
+	
+	// Whitespace alone splits tokens, delimiters are opt-in
+	sst := NewStaticSearchTreeWithOptions(words, Options{TokenizeOnWhitespace: true})
+	if result := sst.Search("roll"); len(result) != 0 {
+		t.Errorf("Search('roll') without '-' as delimiter: expected no matches, got %v", result)
+	}
+	if result := NewStaticSearchTree(words).Search("york"); !reflect.DeepEqual(result, []string{"York"}) {
+		t.Errorf("Search('york') without tokenization: expected [York], got %v", result)
+	}
+}
+
+func TestNewStaticSearchTreeFromReader(t *testing.T) {
+	input := "apple\n\n  banana  \r\n\t\napricot\napple\ncherry\n\n"
+	sst, err := NewStaticSearchTreeFromReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("NewStaticSearchTreeFromReader: unexpected error %v", err)
+	}
+	
+	expected := NewStaticSearchTree([]string{"apple", "banana", "apricot", "apple", "cherry"})
+	if !reflect.DeepEqual(sst.GetAllPrefixes(), expected.GetAllPrefixes()) {
+		t.Errorf("NewStaticSearchTreeFromReader: expected prefixes %v, got %v", expected.GetAllPrefixes(), sst.GetAllPrefixes())
+	}
+	for _, query := range []string{"a", "ban", "cherry"} {
+		if result := sst.Search(query); !reflect.DeepEqual(result, expected.Search(query)) {
+			t.Errorf("Search('%s'): expected %v, got %v", query, expected.Search(query), result)
+		}
+	}
+	
+	// A final line without a newline is still read
+	if sst, err := NewStaticSearchTreeFromReader(strings.NewReader("apple\nbanana")); err != nil || !sst.ExactMatch("banana") {
+		t.Errorf("NewStaticSearchTreeFromReader without trailing newline: expected banana indexed, got error %v", err)
+	}
+	
+	failing := errors.New("disk failure")
+	if _, err := NewStaticSearchTreeFromReader(iotest.ErrReader(failing)); !errors.Is(err, failing) {
+		t.Errorf("NewStaticSearchTreeFromReader with failing reader: expected %v, got %v", failing, err)
+	}
+}
+
+func TestWords(t *testing.T) {
+	input := []string{"banana", "Apple", "apple", "banana", "cherry", "", "apple"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(append([]string(nil), input...), WithBackend(backend))
+		
+		expected := []string{"Apple", "apple", "banana", "cherry"}
+		if words := sst.Words(); !reflect.DeepEqual(words, expected) {
+			t.Errorf("Words() [backend %v]: expected %v, got %v", backend, expected, words)
+		}
+		
+		sst.Insert("date")
+		sst.Insert("banana")
+		sst.Delete("APPLE")
+		expected = []string{"banana", "cherry", "date"}
+		if words := sst.Words(); !reflect.DeepEqual(words, expected) {
+			t.Errorf("Words() after Insert and Delete [backend %v]: expected %v, got %v", backend, expected, words)
+		}
+		
+		data, err := json.Marshal(sst)
+		if err != nil {
+			t.Fatalf("Marshal [backend %v]: %v", backend, err)
+		}
+		var restored StaticSearchTree
+		if err := json.Unmarshal(data, &restored); err != nil {
+			t.Fatalf("Unmarshal [backend %v]: %v", backend, err)
+		}
+		if words := restored.Words(); !reflect.DeepEqual(words, expected) {
+			t.Errorf("Words() after JSON round trip [backend %v]: expected %v, got %v", backend, expected, words)
+		}
+	}
+}
+
+func TestSearchWith(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "app", "application", "apricot", "ape"})
+	
+	byLength := func(a, b string) bool {
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	}
+	reverse := func(a, b string) bool { return a > b }
+	
+	tests := []struct {
+		name     string
+		less     func(a, b string) bool
+		expected []string
+	}{
+		{"nil", nil, []string{"ape", "app", "apple", "application", "apricot"}},
+		{"reverse", reverse, []string{"apricot", "application", "apple", "app", "ape"}},
+		{"length", byLength, []string{"ape", "app", "apple", "apricot", "application"}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.SearchWith("ap", test.less); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("SearchWith('ap', %s): expected %v, got %v", test.name, test.expected, result)
+		}
+	}
+	if result := sst.SearchWith("b", reverse); len(result) != 0 {
+		t.Errorf("SearchWith('b', reverse): expected no matches, got %v", result)
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"ab", "Ac", "b"})
+	
+	var buf bytes.Buffer
+	if err := sst.WriteTree(&buf); err != nil {
+		t.Fatalf("WriteTree returned error: %v", err)
+	}
+	expected := "'a' -> [Ac ab]\n'ab' -> [ab]\n'ac' -> [Ac]\n'b' -> [b]\n"
+	if buf.String() != expected {
+		t.Errorf("WriteTree: expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestSearchSuffix(t *testing.T) {
+	words := []string{"application", "Station", "nation", "apple", "Ration", "stationery", "Äpfelmus", "mus"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		for _, indexed := range []bool{true, false} {
+			sst := NewStaticSearchTree(words, WithBackend(backend), WithSuffixIndex(indexed))
+			
+			tests := []struct {
+				query    string
+				expected []string
+			}{
+				{"tion", []string{"Ration", "Station", "application", "nation"}},
+				{"ATION", []string{"Ration", "Station", "application", "nation"}},
+				{"ation", []string{"Ration", "Station", "application", "nation"}},
+				{"lication", []string{"application"}},
+				{"mus", []string{"mus", "Äpfelmus"}},
+				{"ery", []string{"stationery"}},
+				{"xyz", []string{}},
+				{"", []string{}},
+			}
+			
+			for _, test := range tests {
+				if result := sst.SearchSuffix(test.query); !reflect.DeepEqual(result, test.expected) {
+					t.Errorf("SearchSuffix('%s') [backend %v, index %v]: expected %v, got %v", 
+						test.query, backend, indexed, test.expected, result)
+				}
+			}
+			
+			snap := sst.Snapshot()
+			sst.Insert("motion")
+			sst.Delete("nation")
+			expected := []string{"Ration", "Station", "application", "motion"}
+			if result := sst.SearchSuffix("tion"); !reflect.DeepEqual(result, expected) {
+				t.Errorf("SearchSuffix('tion') after Insert and Delete [backend %v, index %v]: expected %v, got %v", 
+					backend, indexed, expected, result)
+			}
+			if result := snap.sst.SearchSuffix("tion"); len(result) != 4 || contains(result, "motion") {
+				t.Errorf("Snapshot SearchSuffix('tion') [backend %v, index %v]: expected the original 4 words, got %v", 
+					backend, indexed, result)
+			}
+		}
+	}
+}
+
+func TestPreserveInsertionOrder(t *testing.T) {
+	words := []string{"banana", "apricot", "zebra", "Avocado", "apple", "apricot"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend), WithPreserveInsertionOrder(true), WithSuffixIndex(true))
+		
+		expected := []string{"apricot", "Avocado", "apple"}
+		if result := sst.Search("a"); !reflect.DeepEqual(result, expected) {
+			t.Errorf("Search('a') [backend %v]: expected %v, got %v", backend, expected, result)
+		}
+		if result, _ := sst.SearchContext(context.Background(), "a"); !reflect.DeepEqual(result, expected) {
+			t.Errorf("SearchContext('a') [backend %v]: expected %v, got %v", backend, expected, result)
+		}
+		
+		sst.Insert("aardvark")
+		sst.Insert("apple")
+		expected = []string{"apricot", "Avocado", "apple", "aardvark"}
+		if result := sst.Search("a"); !reflect.DeepEqual(result, expected) {
+			t.Errorf("Search('a') after Insert [backend %v]: expected %v, got %v", backend, expected, result)
+		}
+		if result := sst.SearchSuffix("a"); !reflect.DeepEqual(result, []string{"banana", "zebra"}) {
+			t.Errorf("SearchSuffix('a') [backend %v]: expected [banana zebra], got %v", backend, result)
+		}
+		
+		data, err := json.Marshal(sst)
+		if err != nil {
+			t.Fatalf("Marshal [backend %v]: %v", backend, err)
+		}
+		var restored StaticSearchTree
+		if err := json.Unmarshal(data, &restored); err != nil {
+			t.Fatalf("Unmarshal [backend %v]: %v", backend, err)
+		}
+		restored.Insert("ant")
+		expected = append(expected, "ant")
+		if result := restored.Search("a"); !reflect.DeepEqual(result, expected) {
+			t.Errorf("Search('a') after JSON round trip and Insert [backend %v]: expected %v, got %v", backend, expected, result)
+		}
+	}
+	
+	if result := NewStaticSearchTree(words).Search("a"); !reflect.DeepEqual(result, []string{"Avocado", "apple", "apricot"}) {
+		t.Errorf("Search('a') without the option: expected lexicographic order, got %v", result)
+	}
+}
+
+func TestSearchAnyTagged(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "application", "apricot", "banana"})
+	
+	tagged := sst.SearchAnyTagged("ap", "app", "ban", "cherry", "app")
+	expected := map[string][]string{
+		"ap":     {"apple", "application", "apricot"},
+		"app":    {"apple", "application"},
+		"ban":    {"banana"},
+		"cherry": {},
+	}
+	if !reflect.DeepEqual(tagged, expected) {
+		t.Errorf("SearchAnyTagged: expected %v, got %v", expected, tagged)
+	}
+	
+	if tagged := sst.SearchAnyTagged(); len(tagged) != 0 {
+		t.Errorf("SearchAnyTagged(): expected an empty map, got %v", tagged)
+	}
+}
+
+func TestEstimateTreeSize(t *testing.T) {
+	inputs := [][]string{
+		{"app", "apple", "banana", "apple"},
+		{"Apple", "apple", "APPLE", ""},
+		{"日本", "日本語", "über"},
+		randomWords(300),
+		nil,
+	}
+	
+	for _, words := range inputs {
+		prefixes, bytes := EstimateTreeSize(words)
+		sst := NewStaticSearchTree(append([]string(nil), words...), WithBackend(BackendPrefixMap))
+		if prefixes != sst.Size() {
+			t.Errorf("EstimateTreeSize(%d words): expected %d prefixes, got %d", len(words), sst.Size(), prefixes)
+		}
+		if expected := int64(sst.Stats().EstimatedBytes); bytes != expected {
+			t.Errorf("EstimateTreeSize(%d words): expected %d bytes, got %d", len(words), expected, bytes)
+		}
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "banana", "cherry", "Elderberry"})
+	
+	tests := []struct {
+		query    string
+		expected string
+		found    bool
+	}{
+		{"aplpe", "apple", true},
+		{"bananna", "banana", true},
+		{"CHERY", "cherry", true},
+		{"elderbery", "Elderberry", true},
+		{"app", "", false},
+		{"xylophone", "", false},
+		{"qq", "", false},
+		{"", "", false},
+	}
+	
+	for _, test := range tests {
+		suggestion, found := sst.DidYouMean(test.query)
+		if suggestion != test.expected || found != test.found {
+			t.Errorf("DidYouMean('%s'): expected ('%s', %v), got ('%s', %v)", 
+				test.query, test.expected, test.found, suggestion, found)
+		}
+	}
+	
+	if suggestion, found := NewStaticSearchTree(nil).DidYouMean("apple"); found {
+		t.Errorf("DidYouMean('apple') on an empty tree: expected no suggestion, got '%s'", suggestion)
+	}
+}
+
+func TestSearchBatch(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"apple", "application", "banana", "cherry"})
+	queries := []string{"app", "ban", "zzz", "APP", "c", "app"}
+	
+	results := sst.SearchBatch(queries)
+	if len(results) != len(queries) {
+		t.Fatalf("SearchBatch: expected %d results, got %d", len(queries), len(results))
+	}
+	for i, query := range queries {
+		if expected := sst.Search(query); !reflect.DeepEqual(results[i], expected) {
+			t.Errorf("SearchBatch result %d ('%s'): expected %v, got %v", i, query, expected, results[i])
+		}
+	}
+	
+	// Duplicates are equal but independent
+	results[0][0] = "changed"
+	if !reflect.DeepEqual(results[3], []string{"apple", "application"}) || !reflect.DeepEqual(results[5], results[3]) {
+		t.Errorf("SearchBatch duplicates: expected independent equal slices, got %v and %v", results[3], results[5])
+	}
+	
+	if results := sst.SearchBatch(nil); len(results) != 0 {
+		t.Errorf("SearchBatch(nil): expected no results, got %v", results)
+	}
+}
+
+func TestMinQueryLength(t *testing.T) {
+	words := []string{"apple", "application", "apricot", "banana"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend), WithMinQueryLength(2))
+		
+		tests := []struct {
+			query    string
+			expected []string
+		}{
+			{"a", []string{}},
+			{"ap", []string{"apple", "application", "apricot"}},
+			{"APP", []string{"apple", "application"}},
+			{"zz", []string{}},
+		}
+		
+		for _, test := range tests {
+			if result := sst.Search(test.query); !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("[backend %v] Search('%s') with WithMinQueryLength(2): expected %v, got %v", 
+					backend, test.query, test.expected, result)
+			}
+		}
+		
+		for word := range sst.SearchIter("a") {
+			t.Errorf("[backend %v] SearchIter('a'): expected no matches, got '%s'", backend, word)
+		}
+		if result, err := sst.SearchContext(context.Background(), "a"); err != nil || len(result) != 0 {
+			t.Errorf("[backend %v] SearchContext('a'): expected no matches, got %v (%v)", backend, result, err)
+		}
+		streamed := 0
+		sst.SearchSortedStream("a", func(string) bool { streamed++; return true })
+		if streamed != 0 {
+			t.Errorf("[backend %v] SearchSortedStream('a'): expected no matches, got %d", backend, streamed)
+		}
+		if !sst.PrefixExists("a") {
+			t.Errorf("[backend %v] PrefixExists('a'): expected the prefix to stay indexed", backend)
+		}
+		if count := sst.CountMatches("a"); count != 0 {
+			t.Errorf("[backend %v] CountMatches('a'): expected 0, got %d", backend, count)
+		}
+		if sst.IsAmbiguous("a") {
+			t.Errorf("[backend %v] IsAmbiguous('a'): expected false for a suppressed query", backend)
+		}
+		if _, err := sst.ResolveUnique("a"); !errors.Is(err, ErrNoMatch) {
+			t.Errorf("[backend %v] ResolveUnique('a'): expected ErrNoMatch, got %v", backend, err)
+		}
+		
+		// A session must search afresh once the query reaches the minimum length
+		session := sst.NewSession()
+		session.Type('a')
+		if result := session.Type('p'); len(result) != 3 {
+			t.Errorf("[backend %v] Session.Type after 'a': expected 3 matches for 'ap', got %v", backend, result)
+		}
+	}
+	
+	sst := NewStaticSearchTree(words)
+	if result := sst.Search("a"); len(result) != 3 {
+		t.Errorf("Search('a') with the default MinQueryLength: expected 3 matches, got %v", result)
+	}
+	
+	path := filepath.Join(t.TempDir(), "index.sst")
+	if err := NewStaticSearchTree(words, WithMinQueryLength(2)).WriteDiskIndex(path); err != nil {
+		t.Fatalf("WriteDiskIndex: %v", err)
+	}
+	dst, err := OpenDiskIndex(path)
+	if err != nil {
+		t.Fatalf("OpenDiskIndex: %v", err)
+	}
+	defer dst.Close()
+	if result := dst.Search("a"); len(result) != 0 {
+		t.Errorf("DiskSearchTree.Search('a') with WithMinQueryLength(2): expected no matches, got %v", result)
+	}
+	if result := dst.Search("ap"); len(result) != 3 {
+		t.Errorf("DiskSearchTree.Search('ap') with WithMinQueryLength(2): expected 3 matches, got %v", result)
+	}
+}
+
+func TestSearchUniqueNormalized(t *testing.T) {
+	words := []string{"apple", "Apple", "APPLE", "Application", "application", "caf\u00e9", "cafe\u0301", "Cafeteria"}
+	sst := NewStaticSearchTree(words)
+	
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"app", []string{"APPLE", "Application"}},
+		{"caf", []string{"Cafeteria", "cafe\u0301"}},
+		{"zzz", []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.SearchUniqueNormalized(test.query); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("SearchUniqueNormalized('%s'): expected %v, got %v", test.query, test.expected, result)
+		}
+	}
+	
+	if result := sst.Search("app"); len(result) != 5 {
+		t.Errorf("Search('app'): expected all 5 variants, got %v", result)
+	}
+}
+
+func TestPrefixFrequencies(t *testing.T) {
+	words := []string{"car", "cat", "cow", "dog"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend))
+		
+		expected := map[string]int{
+			"c": 3, "ca": 2, "car": 1, "cat": 1, "co": 1, "cow": 1,
+			"d": 1, "do": 1, "dog": 1,
+		}
+		if counts := sst.PrefixFrequencies(); !reflect.DeepEqual(counts, expected) {
+			t.Errorf("[backend %v] PrefixFrequencies(): expected %v, got %v", backend, expected, counts)
+		}
+		
+		tests := []struct {
+			n        int
+			expected []string
+		}{
+			{1, []string{"c"}},
+			{3, []string{"c", "ca", "car"}},
+			{0, []string{}},
+			{20, []string{"c", "ca", "car", "cat", "co", "cow", "d", "do", "dog"}},
+		}
+		for _, test := range tests {
+			if result := sst.TopPrefixes(test.n); !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("[backend %v] TopPrefixes(%d): expected %v, got %v", backend, test.n, test.expected, result)
+			}
+		}
+	}
+}
+
+func TestNormalizeWhitespace(t *testing.T) {
+	words := []string{"  apple ", "apple", "New \t York", "new york", "New  Yorker", "\t"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend), WithNormalizeWhitespace(true))
+		
+		tests := []struct {
+			query    string
+			expected []string
+		}{
+			{"app", []string{"apple"}},
+			{"  APP", []string{"apple"}},
+			{"new y", []string{"New York", "New Yorker", "new york"}},
+			{"NEW\t\tYORKE", []string{"New Yorker"}},
+			{" ", []string{}},
+		}
+		
+		for _, test := range tests {
+			if result := sst.Search(test.query); !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("[backend %v] Search('%s') with WithNormalizeWhitespace: expected %v, got %v", 
+					backend, test.query, test.expected, result)
+			}
+		}
+		
+		if added := sst.Insert(" apple  "); added {
+			t.Errorf("[backend %v] Insert(' apple  '): expected the word to be present already", backend)
+		}
+		if !sst.Delete("new  yorker ") {
+			t.Errorf("[backend %v] Delete('new  yorker '): expected the word to be removed", backend)
+		}
+		if result := sst.Search("new yorke"); len(result) != 0 {
+			t.Errorf("[backend %v] Search('new yorke') after Delete: expected no results, got %v", backend, result)
+		}
+	}
+	
+	// Without the option the variants stay distinct
+	if result := NewStaticSearchTree(words).Search("  app"); !reflect.DeepEqual(result, []string{"  apple "}) {
+		t.Errorf("Search('  app') without WithNormalizeWhitespace: expected [  apple ], got %v", result)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	first := []string{"apple", "application", "banana", "cherry"}
+	second := []string{"apricot", "apple", "blueberry", "date"}
+	combined := NewStaticSearchTree(append(append([]string(nil), first...), second...))
+	queries := []string{"a", "ap", "app", "b", "c", "d", "apple", "zzz"}
+	
+	for _, backends := range [][2]Backend{
+		{BackendPrefixMap, BackendPrefixMap},
+		{BackendRadix, BackendRadix},
+		{BackendPrefixMap, BackendRadix},
+	} {
+		a := NewStaticSearchTree(append([]string(nil), first...), WithBackend(backends[0]), WithSuffixIndex(true))
+		b := NewStaticSearchTree(append([]string(nil), second...), WithBackend(backends[1]), WithSuffixIndex(true))
+		merged := a.Merge(b)
+		
+		for _, query := range queries {
+			expected := combined.Search(query)
+			if result := merged.Search(query); !reflect.DeepEqual(result, expected) {
+				t.Errorf("[backends %v] Merge: Search('%s'): expected %v, got %v", backends, query, expected, result)
+			}
+		}
+		if result := merged.SearchSuffix("rry"); !reflect.DeepEqual(result, []string{"blueberry", "cherry"}) {
+			t.Errorf("[backends %v] Merge: SearchSuffix('rry'): expected [blueberry cherry], got %v", backends, result)
+		}
+		reference := NewStaticSearchTree(append(append([]string(nil), first...), second...), WithBackend(backends[0]))
+		if size := merged.Size(); size != reference.Size() {
+			t.Errorf("[backends %v] Merge: Size(): expected %d, got %d", backends, reference.Size(), size)
+		}
+		
+		// The inputs are left untouched
+		if result := a.Search("d"); len(result) != 0 {
+			t.Errorf("[backends %v] Merge: first tree Search('d'): expected no results, got %v", backends, result)
+		}
+		if result := b.Search("c"); len(result) != 0 {
+			t.Errorf("[backends %v] Merge: second tree Search('c'): expected no results, got %v", backends, result)
+		}
+	}
+}
+
+func TestSearchFirst(t *testing.T) {
+	words := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		words = append(words, fmt.Sprintf("word%d", i))
+	}
+	words = append(words, "Apple", "apricot", "banana")
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(append([]string(nil), words...), WithBackend(backend))
+		
+		for _, query := range []string{"a", "ap", "APR", "w", "word99", "b", "zzz", ""} {
+			var expected string
+			if matches := sst.Search(query); len(matches) > 0 {
+				expected = matches[0]
+			}
+			first, found := sst.SearchFirst(query)
+			if first != expected || found != (expected != "") {
+				t.Errorf("[backend %v] SearchFirst('%s'): expected ('%s', %v), got ('%s', %v)", 
+					backend, query, expected, expected != "", first, found)
+			}
+		}
+	}
+	
+	sst := NewStaticSearchTree(words)
+	if allocs := testing.AllocsPerRun(100, func() { sst.SearchFirst("word") }); allocs != 0 {
+		t.Errorf("SearchFirst('word'): expected no allocations, got %v", allocs)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  []string
+		after   []string
+		added   []string
+		removed []string
+	}{
+		{"overlapping", []string{"apple", "banana", "cherry"}, []string{"banana", "cherry", "date", "apricot"}, 
+			[]string{"apricot", "date"}, []string{"apple"}},
+		{"disjoint", []string{"apple", "banana"}, []string{"cherry"}, 
+			[]string{"cherry"}, []string{"apple", "banana"}},
+		{"identical", []string{"apple", "banana"}, []string{"banana", "apple"}, 
+			[]string{}, []string{}},
+		{"empty before", nil, []string{"apple"}, 
+			[]string{"apple"}, []string{}},
+	}
+	
+	for _, test := range tests {
+		for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+			before := NewStaticSearchTree(test.before, WithBackend(backend))
+			after := NewStaticSearchTree(test.after)
+			added, removed := Diff(before, after)
+			if !reflect.DeepEqual(added, test.added) || !reflect.DeepEqual(removed, test.removed) {
+				t.Errorf("[backend %v] Diff (%s): expected added %v and removed %v, got %v and %v", 
+					backend, test.name, test.added, test.removed, added, removed)
+			}
+		}
+	}
+}
+
+func TestSearchPrefixRange(t *testing.T) {
+	words := []string{"banana", "car", "Cartoon", "cat", "catalog", "dog", "apple"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend))
+		
+		tests := []struct {
+			lo, hi   string
+			expected []string
+		}{
+			{"car", "cat", []string{"Cartoon", "car"}},
+			{"CAR", "cAt", []string{"Cartoon", "car"}},
+			{"cat", "catalog", []string{"cat"}},
+			{"cat", "cat", []string{}},
+			{"dog", "car", []string{}},
+			{"", "", []string{"Cartoon", "apple", "banana", "car", "cat", "catalog", "dog"}},
+			{"b", "d", []string{"Cartoon", "banana", "car", "cat", "catalog"}},
+		}
+		
+		for _, test := range tests {
+			if result := sst.SearchPrefixRange(test.lo, test.hi); !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("[backend %v] SearchPrefixRange('%s', '%s'): expected %v, got %v", 
+					backend, test.lo, test.hi, test.expected, result)
+			}
+		}
+	}
+}
+
+func TestStopWords(t *testing.T) {
+	words := []string{"Lord of the Rings", "The Hobbit", "Tale of Two Cities"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend), WithTokenization(""), WithStopWords("THE", "of"))
+		
+		tests := []struct {
+			query    string
+			expected []string
+		}{
+			{"of", []string{}},
+			{"the r", []string{}},
+			{"rings", []string{"Lord of the Rings"}},
+			{"two", []string{"Tale of Two Cities"}},
+			{"hob", []string{"The Hobbit"}},
+			{"the", []string{"The Hobbit"}},
+			{"lord of", []string{"Lord of the Rings"}},
+		}
+		
+		for _, test := range tests {
+			if result := sst.Search(test.query); !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("[backend %v] Search('%s') with WithStopWords: expected %v, got %v", 
+					backend, test.query, test.expected, result)
+			}
+		}
+	}
+	
+	if result := NewStaticSearchTree(words, WithTokenization("")).Search("of"); len(result) != 2 {
+		t.Errorf("Search('of') without stop words: expected 2 matches, got %v", result)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(nil, WithBackend(backend))
+		for i := 0; i < 2000; i++ {
+			sst.Insert(fmt.Sprintf("word%04d", i))
+		}
+		for i := 0; i < 2000; i++ {
+			if i%20 != 0 {
+				sst.Delete(fmt.Sprintf("word%04d", i))
+			}
+		}
+		
+		queries := []string{"w", "word", "word1", "word10", "word0020", "word0021", "x"}
+		before := make([][]string, len(queries))
+		for i, query := range queries {
+			before[i] = sst.Search(query)
+		}
+		statsBefore := sst.Stats()
+		
+		sst.Compact()
+		
+		for i, query := range queries {
+			if result := sst.Search(query); !reflect.DeepEqual(result, before[i]) {
+				t.Errorf("[backend %v] Search('%s') after Compact: expected %v, got %v", backend, query, before[i], result)
+			}
+		}
+		statsAfter := sst.Stats()
+		if statsAfter.ReservedBytes >= statsBefore.ReservedBytes {
+			t.Errorf("[backend %v] Compact: expected ReservedBytes to drop below %d, got %d", 
+				backend, statsBefore.ReservedBytes, statsAfter.ReservedBytes)
+		}
+		if statsAfter.ReservedBytes != statsAfter.EstimatedBytes {
+			t.Errorf("[backend %v] Compact: expected no spare capacity, got %d reserved for %d estimated bytes", 
+				backend, statsAfter.ReservedBytes, statsAfter.EstimatedBytes)
+		}
+		if statsAfter.Prefixes != statsBefore.Prefixes || statsAfter.Words != 100 {
+			t.Errorf("[backend %v] Compact: expected %d prefixes and 100 words, got %d and %d", 
+				backend, statsBefore.Prefixes, statsAfter.Prefixes, statsAfter.Words)
+		}
+	}
+}
+
+func TestFoldAccents(t *testing.T) {
+	words := []string{"café", "Crème brûlée", "jalapeño", "Ångström", "naïve", "façade", "Zoë", "cafeteria"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend), WithFoldAccents(true))
+		
+		tests := []struct {
+			query    string
+			expected []string
+		}{
+			{"cafe", []string{"cafeteria", "café"}},
+			{"café", []string{"cafeteria", "café"}},
+			{"creme brul", []string{"Crème brûlée"}},
+			{"JALAPENO", []string{"jalapeño"}},
+			{"angs", []string{"Ångström"}},
+			{"naiv", []string{"naïve"}},
+			{"facade", []string{"façade"}},
+			{"zoe", []string{"Zoë"}},
+			{"cafe\u0301", []string{"cafeteria", "café"}},
+		}
+		
+		for _, test := range tests {
+			if result := sst.Search(test.query); !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("[backend %v] Search('%s') with WithFoldAccents: expected %v, got %v", 
+					backend, test.query, test.expected, result)
+			}
+		}
+	}
+	
+	// Combined with Unicode case folding
+	sst := NewStaticSearchTree([]string{"Straße", "Élan"}, WithFoldAccents(true), WithUnicodeFolding(true))
+	if result := sst.Search("elan"); !reflect.DeepEqual(result, []string{"Élan"}) {
+		t.Errorf("Search('elan') with WithFoldAccents and WithUnicodeFolding: expected [Élan], got %v", result)
+	}
+	if result := sst.Search("strass"); !reflect.DeepEqual(result, []string{"Straße"}) {
+		t.Errorf("Search('strass') with WithFoldAccents and WithUnicodeFolding: expected [Straße], got %v", result)
+	}
+	
+	if result := NewStaticSearchTree(words).Search("cafe"); !reflect.DeepEqual(result, []string{"cafeteria"}) {
+		t.Errorf("Search('cafe') without WithFoldAccents: expected [cafeteria], got %v", result)
+	}
+}
+
+func TestNewStaticSearchTreeParallel(t *testing.T) {
+	words := append(randomWords(1000), "apple", "Apple", "apple", "app le", "new  york", "new york")
+	
+	for _, opts := range [][]Option{
+		nil,
+		{WithTokenization("")},
+		{WithMaxPrefixLength(3)},
+		{WithNormalizeWhitespace(true), WithSuffixIndex(true)},
+		{WithBackend(BackendPrefixMap)},
+	} {
+		serial := NewStaticSearchTree(append([]string(nil), words...), opts...)
+		for _, workers := range []int{1, 2, 3, 8, 10000} {
+			parallel := NewStaticSearchTreeParallel(append([]string(nil), words...), workers, opts...)
+			if !sameIndex(parallel, serial) || !reflect.DeepEqual(parallel.words, serial.words) {
+				t.Errorf("NewStaticSearchTreeParallel(%d workers, %d options): index differs from the serial build", 
+					workers, len(opts))
+			}
+			for _, query := range []string{"a", "app", "new y", "qz", "zzz"} {
+				if result, expected := parallel.Search(query), serial.Search(query); !reflect.DeepEqual(result, expected) {
+					t.Errorf("NewStaticSearchTreeParallel(%d workers, %d options): Search('%s'): expected %v, got %v", 
+						workers, len(opts), query, expected, result)
+				}
+			}
+			if result, expected := parallel.SearchSuffix("le"), serial.SearchSuffix("le"); !reflect.DeepEqual(result, expected) {
+				t.Errorf("NewStaticSearchTreeParallel(%d workers, %d options): SearchSuffix('le'): expected %v, got %v", 
+					workers, len(opts), expected, result)
+			}
+			if parallel.version != serial.version {
+				t.Errorf("NewStaticSearchTreeParallel(%d workers): expected version %d, got %d", 
+					workers, serial.version, parallel.version)
+			}
+		}
+	}
+}
+
+func TestSearchCompletions(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"app", "App", "apple", "application", "banana"})
+	
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"app", []string{"apple", "application"}},
+		{"APP", []string{"apple", "application"}},
+		{"ap", []string{"App", "app", "apple", "application"}},
+		{"appl", []string{"apple", "application"}},
+		{"banana", []string{}},
+		{"zzz", []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.SearchCompletions(test.query); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("SearchCompletions('%s'): expected %v, got %v", test.query, test.expected, result)
+		}
+	}
+	
+	if result := sst.Search("app"); len(result) != 4 {
+		t.Errorf("Search('app'): expected the exact words as well, got %v", result)
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	words := randomWords(2000)
+	plain := NewStaticSearchTree(append([]string(nil), words...))
+	filtered := NewStaticSearchTree(append([]string(nil), words...), WithBackend(BackendPrefixMap), WithBloomFilter(true))
+	
+	queries := []string{"a", "ab", "abc", "zzzzzz", "qqqq", "", words[0], words[1][:3], "xkcd"}
+	for i := 0; i < 200; i++ {
+		queries = append(queries, fmt.Sprintf("%c%c%d", 'a'+i%26, 'a'+i/26%26, i))
+	}
+	check := func(stage string) {
+		for _, query := range queries {
+			if result, expected := filtered.Search(query), plain.Search(query); !reflect.DeepEqual(result, expected) {
+				t.Errorf("Search('%s') with WithBloomFilter %s: expected %v, got %v", query, stage, expected, result)
+			}
+		}
+	}
+	check("after build")
+	if filtered.bloom.rejected.Load() == 0 {
+		t.Errorf("WithBloomFilter: expected absent prefixes to be rejected by the filter")
+	}
+	
+	// Inserting past the filter's capacity resizes it without losing prefixes
+	for i := 0; i < 3000; i++ {
+		word := fmt.Sprintf("zz%d", i)
+		plain.Insert(word)
+		filtered.Insert(word)
+	}
+	queries = append(queries, "zz", "zz1", "zz2999")
+	check("after Insert")
+	
+	plain.Delete(words[0])
+	filtered.Delete(words[0])
+	filtered.Compact()
+	check("after Delete and Compact")
+	
+	snapshot := filtered.Snapshot()
+	filtered.Insert("xkcd")
+	if result := snapshot.Search("xkcd"); len(result) != 0 {
+		t.Errorf("SearchSnapshot.Search('xkcd') after Insert: expected no results, got %v", result)
+	}
+	if result := filtered.Search("xkcd"); !reflect.DeepEqual(result, []string{"xkcd"}) {
+		t.Errorf("Search('xkcd') with WithBloomFilter after Insert: expected [xkcd], got %v", result)
+	}
+	
+	var buf bytes.Buffer
+	if err := filtered.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded := NewStaticSearchTree(nil)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if result := loaded.Search("xkcd"); !reflect.DeepEqual(result, []string{"xkcd"}) {
+		t.Errorf("Search('xkcd') after Load: expected [xkcd], got %v", result)
+	}
+	
+	filtered.Clear()
+	if result := filtered.Search("a"); len(result) != 0 {
+		t.Errorf("Search('a') with WithBloomFilter after Clear: expected no results, got %v", result)
+	}
+}
+
+func TestSearchWithTag(t *testing.T) {
+	sst := NewStaticSearchTreeWithTags(map[string][]string{
+		"apple":      {"fruit", "company"},
+		"apricot":    {"fruit"},
+		"appliance":  {"home"},
+		"Apple Pie":  {"dessert", "fruit"},
+		"banana":     {"fruit"},
+		"blueprint":  nil,
+	})
+	
+	tests := []struct {
+		query, tag string
+		expected   []string
+	}{
+		{"ap", "fruit", []string{"Apple Pie", "apple", "apricot"}},
+		{"app", "company", []string{"apple"}},
+		{"app", "home", []string{"appliance"}},
+		{"b", "fruit", []string{"banana"}},
+		{"ap", "vegetable", []string{}},
+		{"zzz", "fruit", []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.SearchWithTag(test.query, test.tag); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("SearchWithTag('%s', '%s'): expected %v, got %v", test.query, test.tag, test.expected, result)
+		}
+	}
+	
+	if result := sst.Search("ap"); len(result) != 4 {
+		t.Errorf("Search('ap'): expected matches of every tag, got %v", result)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	words := []string{"apple", "application", "banana"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend), WithTokenization(""))
+		sst.InsertAll([]string{"apricot", "big apple"})
+		sst.Delete("banana")
+		if err := sst.Validate(); err != nil {
+			t.Errorf("[backend %v] Validate after Insert and Delete: expected no error, got %v", backend, err)
+		}
+	}
+	
+	tests := []struct {
+		name     string
+		corrupt  func(sst *StaticSearchTree)
+		expected string
+	}{
+		{"foreign word", func(sst *StaticSearchTree) { sst.tree["ap"] = []string{"apple", "banana"} }, 
+			`prefix "ap" lists "banana", which does not start with it`},
+		{"duplicate", func(sst *StaticSearchTree) { sst.tree["b"] = []string{"banana", "banana"} }, 
+			`prefix "b" lists "banana" twice`},
+		{"empty list", func(sst *StaticSearchTree) { sst.tree["zz"] = []string{} }, 
+			`prefix "zz" has no matches`},
+		{"radix foreign word", func(sst *StaticSearchTree) { sst.radix.find("banana").words = []string{"apple"} }, 
+			`node "banana" holds "apple", which is not indexed under it`},
+	}
+	
+	for _, test := range tests {
+		backend := BackendPrefixMap
+		if strings.HasPrefix(test.name, "radix") {
+			backend = BackendRadix
+		}
+		sst := NewStaticSearchTree(words, WithBackend(backend))
+		test.corrupt(sst)
+		err := sst.Validate()
+		if !errors.Is(err, ErrCorruptTree) || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Validate (%s): expected an ErrCorruptTree reporting %s, got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+func TestSearchWithScore(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"cat", "catalog", "catastrophe", "cats", "dog"})
+	
+	results := sst.SearchWithScore("cat")
+	var order []string
+	for _, result := range results {
+		order = append(order, result.Word)
+	}
+	expected := []string{"cat", "cats", "catalog", "catastrophe"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("SearchWithScore('cat'): expected order %v, got %v", expected, order)
+	}
+	if results[0].Score != 1 || math.Abs(results[1].Score-0.75) > 1e-9 {
+		t.Errorf("SearchWithScore('cat'): expected scores 1 and 0.75 for the exact and four-letter words, got %v", results[:2])
+	}
+	
+	// A heavily used long word can overtake a barely used short one
+	sst.SetWeight("catalog", 100)
+	if results := sst.SearchWithScore("cat"); results[0].Word != "catalog" {
+		t.Errorf("SearchWithScore('cat') with a weight on 'catalog': expected it first, got %v", results)
+	}
+	
+	if results := sst.SearchWithScore("zzz"); len(results) != 0 {
+		t.Errorf("SearchWithScore('zzz'): expected no results, got %v", results)
+	}
+}
+
+func TestNewStaticSearchTreeFromGzip(t *testing.T) {
+	words := []string{"apple", "application", "banana", "cherry", "Ünïcode"}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, strings.Join(words, "\n")+"\n"); err != nil {
+		t.Fatalf("gzip write: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	
+	sst, err := NewStaticSearchTreeFromGzip(&buf)
+	if err != nil {
+		t.Fatalf("NewStaticSearchTreeFromGzip: unexpected error %v", err)
+	}
+	plain := NewStaticSearchTree(words)
+	if !reflect.DeepEqual(sst.Words(), plain.Words()) {
+		t.Errorf("NewStaticSearchTreeFromGzip: expected words %v, got %v", plain.Words(), sst.Words())
+	}
+	for _, query := range []string{"a", "app", "ü", "zzz"} {
+		if result, expected := sst.Search(query), plain.Search(query); !reflect.DeepEqual(result, expected) {
+			t.Errorf("NewStaticSearchTreeFromGzip: Search('%s'): expected %v, got %v", query, expected, result)
+		}
+	}
+	
+	if _, err := NewStaticSearchTreeFromGzip(strings.NewReader("apple\nbanana\ncherry\n")); !errors.Is(err, gzip.ErrHeader) {
+		t.Errorf("NewStaticSearchTreeFromGzip(plain text): expected gzip.ErrHeader, got %v", err)
+	}
+	
+	// Truncated data fails while scanning
+	var truncated bytes.Buffer
+	zw = gzip.NewWriter(&truncated)
+	io.WriteString(zw, strings.Repeat("word\n", 1000))
+	zw.Close()
+	if _, err := NewStaticSearchTreeFromGzip(bytes.NewReader(truncated.Bytes()[:truncated.Len()/2])); err == nil {
+		t.Errorf("NewStaticSearchTreeFromGzip(truncated): expected an error, got nil")
+	}
+}
+
+func TestDefaultLimit(t *testing.T) {
+	words := []string{"apple", "application", "apply", "apricot", "banana"}
+	sst := NewStaticSearchTree(words, WithDefaultLimit(2))
+	
+	if result := sst.Search("ap"); !reflect.DeepEqual(result, []string{"apple", "application"}) {
+		t.Errorf("Search('ap') with WithDefaultLimit(2): expected [apple application], got %v", result)
+	}
+	if result := sst.Search("b"); !reflect.DeepEqual(result, []string{"banana"}) {
+		t.Errorf("Search('b') with WithDefaultLimit(2): expected [banana], got %v", result)
+	}
+	if result := sst.SearchWithLimit("ap", 3); len(result) != 3 {
+		t.Errorf("SearchWithLimit('ap', 3) with WithDefaultLimit(2): expected 3 results, got %v", result)
+	}
+	if result := sst.SearchWithLimit("ap", 1); len(result) != 1 {
+		t.Errorf("SearchWithLimit('ap', 1) with WithDefaultLimit(2): expected 1 result, got %v", result)
+	}
+	var iterated []string
+	for word := range sst.SearchIter("ap") {
+		iterated = append(iterated, word)
+	}
+	if !reflect.DeepEqual(iterated, []string{"apple", "application"}) {
+		t.Errorf("SearchIter('ap') with WithDefaultLimit(2): expected [apple application], got %v", iterated)
+	}
+	if results := sst.SearchBatch([]string{"ap", "AP", "b"}); !reflect.DeepEqual(results, [][]string{
+		{"apple", "application"}, {"apple", "application"}, {"banana"},
+	}) {
+		t.Errorf("SearchBatch(['ap' 'AP' 'b']) with WithDefaultLimit(2): expected each capped at 2, got %v", results)
+	}
+	
+	if result := NewStaticSearchTree(words).Search("ap"); len(result) != 4 {
+		t.Errorf("Search('ap') without a default limit: expected 4 results, got %v", result)
+	}
+	
+	// Cursor pagination walks past the cap
+	snap := sst.Snapshot()
+	var pages [][]string
+	cursor := ""
+	for {
+		page, next := snap.SearchAfter("ap", cursor, 3)
+		pages = append(pages, page)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if expected := [][]string{{"apple", "application", "apply"}, {"apricot"}}; !reflect.DeepEqual(pages, expected) {
+		t.Errorf("SearchAfter('ap') pages with WithDefaultLimit(2): expected %v, got %v", expected, pages)
+	}
+}
+
+func TestNextChars(t *testing.T) {
+	sst := NewStaticSearchTree([]string{"car", "care", "cat", "Cañon", "café", "dog"})
+	
+	tests := []struct {
+		query    string
+		expected []rune
+	}{
+		{"ca", []rune{'f', 'r', 't', 'ñ'}},
+		{"car", []rune{'e'}},
+		{"CAF", []rune{'é'}},
+		{"care", []rune{}},
+		{"dog", []rune{}},
+		{"x", []rune{}},
+	}
+	
+	for _, test := range tests {
+		if result := sst.NextChars(test.query); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("NextChars('%s'): expected %q, got %q", test.query, test.expected, result)
+		}
+	}
+}
+
+func TestToDOT(t *testing.T) {
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree([]string{"an", "and", "ax", `a"b`}, WithBackend(backend))
+		var buf bytes.Buffer
+		if err := sst.ToDOT(&buf); err != nil {
+			t.Fatalf("[backend %v] ToDOT: unexpected error %v", backend, err)
+		}
+		dot := buf.String()
+		
+		for _, expected := range []string{
+			"digraph sst {\n",
+			"\troot -> \"a\";\n",
+			"\t\"a\" [shape=circle];\n",
+			"\t\"an\" [shape=doublecircle];\n",
+			"\t\"a\" -> \"an\";\n",
+			"\t\"an\" -> \"and\";\n",
+			"\t\"and\" [shape=doublecircle];\n",
+			"\t\"a\\\"\" -> \"a\\\"b\";\n",
+		} {
+			if !strings.Contains(dot, expected) {
+				t.Errorf("[backend %v] ToDOT: expected output to contain %q, got:\n%s", backend, expected, dot)
+			}
+		}
+		if strings.Contains(dot, "\"ax\" ->") || !strings.HasSuffix(dot, "}\n") {
+			t.Errorf("[backend %v] ToDOT: unexpected edges or missing closing brace:\n%s", backend, dot)
+		}
+	}
+}
+
+func TestMatchMode(t *testing.T) {
+	words := []string{"app", "App", "apple", "pineapple", "snapper", "banana"}
+	
+	tests := []struct {
+		mode     MatchMode
+		query    string
+		expected []string
+	}{
+		{MatchPrefix, "app", []string{"App", "app", "apple"}},
+		{MatchExact, "app", []string{"app"}},
+		{MatchExact, "APP", []string{"App"}},
+		{MatchExact, "appl", []string{}},
+		{MatchContains, "app", []string{"App", "app", "apple", "pineapple", "snapper"}},
+		{MatchContains, "nan", []string{"banana"}},
+		{MatchContains, "", []string{}},
+	}
+	
+	for _, test := range tests {
+		for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+			sst := NewStaticSearchTree(words, WithBackend(backend), WithMatchMode(test.mode))
+			if result := sst.Search(test.query); !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("[backend %v] Search('%s') with MatchMode %d: expected %v, got %v", 
+					backend, test.query, test.mode, test.expected, result)
+			}
+			var iterated []string
+			for word := range sst.SearchIter(test.query) {
+				iterated = append(iterated, word)
+			}
+			if len(iterated) != len(test.expected) {
+				t.Errorf("[backend %v] SearchIter('%s') with MatchMode %d: expected %v, got %v", 
+					backend, test.query, test.mode, test.expected, iterated)
+			}
+			if first, found := sst.SearchFirst(test.query); found != (len(test.expected) > 0) || (found && first != test.expected[0]) {
+				t.Errorf("[backend %v] SearchFirst('%s') with MatchMode %d: expected the first of %v, got '%s'", 
+					backend, test.query, test.mode, test.expected, first)
+			}
+			if batch := sst.SearchBatch([]string{test.query, test.query}); !reflect.DeepEqual(batch[0], test.expected) || !reflect.DeepEqual(batch[1], test.expected) {
+				t.Errorf("[backend %v] SearchBatch('%s') with MatchMode %d: expected %v twice, got %v", 
+					backend, test.query, test.mode, test.expected, batch)
+			}
+			if result, err := sst.SearchContext(context.Background(), test.query); err != nil || !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("[backend %v] SearchContext('%s') with MatchMode %d: expected %v, got %v (%v)", 
+					backend, test.query, test.mode, test.expected, result, err)
+			}
+			session := sst.NewSession()
+			var typed []string
+			for _, ch := range test.query {
+				typed = session.Type(ch)
+			}
+			if test.query != "" && !reflect.DeepEqual(typed, test.expected) {
+				t.Errorf("[backend %v] Session typing '%s' with MatchMode %d: expected %v, got %v", 
+					backend, test.query, test.mode, test.expected, typed)
+			}
+		}
+	}
+	
+	// Narrowing the matches for "an" to those containing "ana" would keep only
+	// "ananas", although "banana" contains "ana" too
+	sst := NewStaticSearchTree([]string{"ananas", "banana"}, WithMatchMode(MatchContains))
+	expected := []string{"ananas", "banana"}
+	if result := sst.Search("ana"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Search('ana') with MatchContains: expected %v, got %v", expected, result)
+	}
+	if batch := sst.SearchBatch([]string{"ana"}); !reflect.DeepEqual(batch[0], expected) {
+		t.Errorf("SearchBatch('ana') with MatchContains: expected %v, got %v", expected, batch[0])
+	}
+	session := sst.NewSession()
+	var typed []string
+	for _, ch := range "ana" {
+		typed = session.Type(ch)
+	}
+	if !reflect.DeepEqual(typed, expected) {
+		t.Errorf("Session typing 'ana' with MatchContains: expected %v, got %v", expected, typed)
+	}
+}
+
+func TestNilTree(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		sst  *StaticSearchTree
+	}{
+		{"nil pointer", nil},
+		{"zero value", &StaticSearchTree{}},
+	} {
+		if result := test.sst.Search("a"); result == nil || len(result) != 0 {
+			t.Errorf("Search('a') on a %s: expected an empty slice, got %#v", test.name, result)
+		}
+		if result := test.sst.SearchWithLimit("a", 5); result == nil || len(result) != 0 {
+			t.Errorf("SearchWithLimit('a', 5) on a %s: expected an empty slice, got %#v", test.name, result)
+		}
+		if size := test.sst.Size(); size != 0 {
+			t.Errorf("Size() on a %s: expected 0, got %d", test.name, size)
+		}
+		if prefixes := test.sst.GetAllPrefixes(); len(prefixes) != 0 {
+			t.Errorf("GetAllPrefixes() on a %s: expected no prefixes, got %v", test.name, prefixes)
+		}
+	}
+}
+
+func TestEach(t *testing.T) {
+	words := []string{"car", "cat", "dog"}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		sst := NewStaticSearchTree(words, WithBackend(backend))
+		
+		var prefixes []string
+		visits := make(map[string][]string)
+		sst.Each(func(prefix string, matches []string) bool {
+			prefixes = append(prefixes, prefix)
+			visits[prefix] = append([]string(nil), matches...)
+			return true
+		})
+		if expected := sst.GetAllPrefixes(); !reflect.DeepEqual(prefixes, expected) {
+			t.Errorf("[backend %v] Each: expected prefixes %v, got %v", backend, expected, prefixes)
+		}
+		for prefix, matches := range visits {
+			if expected := sst.Search(prefix); !reflect.DeepEqual(matches, expected) {
+				t.Errorf("[backend %v] Each: prefix '%s': expected matches %v, got %v", backend, prefix, expected, matches)
+			}
+		}
+		
+		visited := 0
+		sst.Each(func(prefix string, matches []string) bool {
+			visited++
+			return prefix != "car"
+		})
+		if visited != 3 {
+			t.Errorf("[backend %v] Each stopping at 'car': expected 3 calls, got %d", backend, visited)
+		}
+	}
+}
+
+func TestMultiFieldSearch(t *testing.T) {
+	m := NewMultiFieldSearchTree()
+	m.AddField("title", []string{"Dune", "Dune Messiah", "Foundation"})
+	m.AddField("author", []string{"Frank Herbert", "Dune Smith", "Isaac Asimov"})
+	m.AddField("title", []string{"Children of Dune"})
+	
+	tests := []struct {
+		field, query string
+		expected     []string
+	}{
+		{"title", "dune", []string{"Dune", "Dune Messiah"}},
+		{"author", "dune", []string{"Dune Smith"}},
+		{"title", "f", []string{"Foundation"}},
+		{"author", "f", []string{"Frank Herbert"}},
+		{"title", "child", []string{"Children of Dune"}},
+		{"genre", "dune", []string{}},
+	}
+	
+	for _, test := range tests {
+		if result := m.Search(test.field, test.query); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Search('%s', '%s'): expected %v, got %v", test.field, test.query, test.expected, result)
+		}
+	}
+	
+	expected := map[string][]string{
+		"title":  {"Dune", "Dune Messiah"},
+		"author": {"Dune Smith"},
+	}
+	if result := m.SearchAllFields("du"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("SearchAllFields('du'): expected %v, got %v", expected, result)
+	}
+	if result := m.SearchAllFields("isaac"); len(result["title"]) != 0 || len(result["author"]) != 1 {
+		t.Errorf("SearchAllFields('isaac'): expected one author match only, got %v", result)
+	}	
+	// Words added by later calls share prefixes with the earlier ones
+	batches := [][]string{{"apricot", "banana"}, {"apple", "Apricot Jam"}, {"app", "bandana"}}
+	for _, backend := range []Backend{BackendPrefixMap, BackendRadix} {
+		m := NewMultiFieldSearchTree(WithBackend(backend))
+		var combined []string
+		for _, batch := range batches {
+			m.AddField("f", batch)
+			combined = append(combined, batch...)
+		}
+		expected := NewStaticSearchTree(combined, WithBackend(backend))
+		for _, query := range []string{"a", "ap", "apr", "b", "ban"} {
+			if result, want := m.Search("f", query), expected.Search(query); !reflect.DeepEqual(result, want) {
+				t.Errorf("[backend %v] Search('f', '%s') after several AddField calls: expected %v, got %v", 
+					backend, query, want, result)
+			}
+		}
+		m.fields["f"].Insert("apron")
+		m.fields["f"].Delete("banana")
+		if err := m.fields["f"].Validate(); err != nil {
+			t.Errorf("[backend %v] Validate after AddField, Insert and Delete: unexpected error %v", backend, err)
+		}
+		if result := m.Search("f", "apr"); !reflect.DeepEqual(result, []string{"Apricot Jam", "apricot", "apron"}) {
+			t.Errorf("[backend %v] Search('f', 'apr') after Insert: expected [Apricot Jam apricot apron], got %v", backend, result)
+		}
+	}
+}
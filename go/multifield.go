@@ -0,0 +1,146 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// RecordID identifies a record indexed in a MultiFieldSearchTree
+type RecordID int
+
+// MultiFieldSearchTree indexes records that have several named text fields, keeping
+// a separate prefix tree per field so queries can be scoped to one field or combined
+// across fields by record. It is safe for concurrent use: mu guards the field and
+// record maps, and each field tree has its own lock, always taken after mu.
+type MultiFieldSearchTree struct {
+	mu      sync.RWMutex
+	opts    Options
+	fields  map[string]*StaticSearchTree
+	records map[string]map[string][]RecordID
+}
+
+// NewMultiFieldSearchTree creates an empty multi-field tree. The options apply to
+// every field.
+func NewMultiFieldSearchTree(opts ...Option) *MultiFieldSearchTree {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &MultiFieldSearchTree{
+		opts:    options,
+		fields:  make(map[string]*StaticSearchTree),
+		records: make(map[string]map[string][]RecordID),
+	}
+}
+
+// field returns the tree for name, creating it if needed; the caller must hold
+// the write lock
+func (m *MultiFieldSearchTree) field(name string) *StaticSearchTree {
+	sst, exists := m.fields[name]
+	if !exists {
+		sst = newTree(m.opts)
+		m.fields[name] = sst
+		m.records[name] = make(map[string][]RecordID)
+	}
+	return sst
+}
+
+// AddRecord indexes each field value of the record under its field name
+func (m *MultiFieldSearchTree) AddRecord(id RecordID, fields map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, value := range fields {
+		m.field(name).Insert(value)
+		
+		// Searches return the stored form, so records are keyed by it
+		value = m.opts.storedForm(value)
+		ids := m.records[name][value]
+		pos := sort.Search(len(ids), func(i int) bool { return ids[i] >= id })
+		if pos < len(ids) && ids[pos] == id {
+			continue
+		}
+		ids = append(ids, 0)
+		copy(ids[pos+1:], ids[pos:])
+		ids[pos] = id
+		m.records[name][value] = ids
+	}
+}
+
+// AddField indexes words under the field name without associating them with any
+// record, creating the field if needed. Words already in the field are kept, and
+// the match lists stay in the order a single build of all the words produces.
+func (m *MultiFieldSearchTree) AddField(name string, words []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.field(name).InsertAll(words)
+}
+
+// Search returns the words of field starting with query, in the order of
+// StaticSearchTree.Search. An unknown field has no matches.
+func (m *MultiFieldSearchTree) Search(field, query string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sst, exists := m.fields[field]
+	if !exists {
+		return []string{}
+	}
+	return sst.Search(query)
+}
+
+// SearchAllFields searches every field for query and maps each field name to its
+// own matches, so a word present in several fields is listed under each
+func (m *MultiFieldSearchTree) SearchAllFields(query string) map[string][]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	results := make(map[string][]string, len(m.fields))
+	for name, sst := range m.fields {
+		results[name] = sst.Search(query)
+	}
+	return results
+}
+
+// SearchFieldPrefix returns the sorted IDs of records whose value for field starts
+// with query
+func (m *MultiFieldSearchTree) SearchFieldPrefix(field, query string) []RecordID {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sst, exists := m.fields[field]
+	if !exists {
+		return []RecordID{}
+	}
+	seen := make(map[RecordID]bool)
+	ids := []RecordID{}
+	for _, value := range sst.Search(query) {
+		for _, id := range m.records[field][value] {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// SearchWithFieldPrefix returns the sorted IDs of records whose field1 value starts
+// with query1 and whose field2 value starts with query2
+func (m *MultiFieldSearchTree) SearchWithFieldPrefix(field1, query1, field2, query2 string) []RecordID {
+	first := m.SearchFieldPrefix(field1, query1)
+	second := m.SearchFieldPrefix(field2, query2)
+	
+	// Both lists are sorted, so intersect them with a linear merge
+	ids := []RecordID{}
+	for i, j := 0, 0; i < len(first) && j < len(second); {
+		switch {
+		case first[i] < second[j]:
+			i++
+		case first[i] > second[j]:
+			j++
+		default:
+			ids = append(ids, first[i])
+			i++
+			j++
+		}
+	}
+	return ids
+}
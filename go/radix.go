@@ -0,0 +1,259 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// radixNode is a node of the compressed trie used by BackendRadix. Each node
+// stores only its outgoing edges and the original words whose normalized form
+// ends exactly at the node, so memory grows with the total length of the words
+// rather than with the number of prefixes times their match counts.
+type radixNode struct {
+	edges []radixEdge
+	words []string
+}
+
+// radixEdge is a labelled edge of the compressed trie. Labels are non-empty and
+// the edges of a node are kept sorted by their first byte.
+type radixEdge struct {
+	label string
+	node  *radixNode
+}
+
+// edgeIndex returns the position of the edge starting with b, or the position
+// where such an edge would be inserted and false
+func (n *radixNode) edgeIndex(b byte) (int, bool) {
+	i := sort.Search(len(n.edges), func(i int) bool { return n.edges[i].label[0] >= b })
+	return i, i < len(n.edges) && n.edges[i].label[0] == b
+}
+
+// insert adds word under the normalized key, splitting edges as needed, and
+// reports whether the word was not already present
+func (n *radixNode) insert(key, word string) bool {
+	if key == "" {
+		pos := sort.SearchStrings(n.words, word)
+		if pos < len(n.words) && n.words[pos] == word {
+			return false
+		}
+		n.words = append(n.words, "")
+		copy(n.words[pos+1:], n.words[pos:])
+		n.words[pos] = word
+		return true
+	}
+	
+	i, found := n.edgeIndex(key[0])
+	if !found {
+		n.edges = append(n.edges, radixEdge{})
+		copy(n.edges[i+1:], n.edges[i:])
+		n.edges[i] = radixEdge{label: key, node: &radixNode{words: []string{word}}}
+		return true
+	}
+	
+	edge := &n.edges[i]
+	common := commonPrefixLen(edge.label, key)
+	if common < len(edge.label) {
+		// Split the edge so that the shared part becomes its own node
+		mid := &radixNode{edges: []radixEdge{{label: edge.label[common:], node: edge.node}}}
+		edge.label = edge.label[:common]
+		edge.node = mid
+	}
+	return edge.node.insert(key[common:], word)
+}
+
+// remove deletes the words stored under the normalized key for which victim
+// returns true, pruning nodes that become empty and merging edges left with a
+// single child. It reports whether any word was removed.
+func (n *radixNode) remove(key string, victim func(string) bool) bool {
+	if key == "" {
+		remaining := n.words[:0:0]
+		for _, word := range n.words {
+			if !victim(word) {
+				remaining = append(remaining, word)
+			}
+		}
+		if len(remaining) == len(n.words) {
+			return false
+		}
+		n.words = remaining
+		return true
+	}
+	
+	i, found := n.edgeIndex(key[0])
+	if !found || !strings.HasPrefix(key, n.edges[i].label) {
+		return false
+	}
+	edge := &n.edges[i]
+	if !edge.node.remove(key[len(edge.label):], victim) {
+		return false
+	}
+	
+	child := edge.node
+	if len(child.words) == 0 {
+		switch len(child.edges) {
+		case 0:
+			n.edges = append(n.edges[:i], n.edges[i+1:]...)
+		case 1:
+			edge.label += child.edges[0].label
+			edge.node = child.edges[0].node
+		}
+	}
+	return true
+}
+
+// find returns the node at or directly below the end of prefix, i.e. the root of
+// the subtree holding every key that starts with prefix
+func (n *radixNode) find(prefix string) *radixNode {
+	for prefix != "" {
+		i, found := n.edgeIndex(prefix[0])
+		if !found {
+			return nil
+		}
+		edge := n.edges[i]
+		if strings.HasPrefix(prefix, edge.label) {
+			prefix = prefix[len(edge.label):]
+			n = edge.node
+			continue
+		}
+		if strings.HasPrefix(edge.label, prefix) {
+			return edge.node
+		}
+		return nil
+	}
+	return n
+}
+
+// path returns the edge labels leading from n to the node where key ends, or nil
+// if no word is stored under key
+func (n *radixNode) path(key string) []string {
+	var labels []string
+	for key != "" {
+		i, found := n.edgeIndex(key[0])
+		if !found || !strings.HasPrefix(key, n.edges[i].label) {
+			return nil
+		}
+		labels = append(labels, n.edges[i].label)
+		key = key[len(n.edges[i].label):]
+		n = n.edges[i].node
+	}
+	if len(n.words) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// collect appends every word stored in the subtree rooted at n
+func (n *radixNode) collect(words []string) []string {
+	words = append(words, n.words...)
+	for _, edge := range n.edges {
+		words = edge.node.collect(words)
+	}
+	return words
+}
+
+// walk calls fn for every word in the subtree rooted at n in depth-first order,
+// which is sorted by normalized key, and stops as soon as fn returns false. It
+// reports whether the walk ran to completion.
+func (n *radixNode) walk(fn func(string) bool) bool {
+	for _, word := range n.words {
+		if !fn(word) {
+			return false
+		}
+	}
+	for _, edge := range n.edges {
+		if !edge.node.walk(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// visitCounts calls fn for every edge below n with the path leading to the edge
+// and the number of words below it, which is the match count of each prefix the
+// edge label spells out. It returns the number of words in the subtree.
+func (n *radixNode) visitCounts(path string, fn func(path string, edge radixEdge, count int)) int {
+	count := len(n.words)
+	for _, edge := range n.edges {
+		below := edge.node.visitCounts(path+edge.label, fn)
+		fn(path, edge, below)
+		count += below
+	}
+	return count
+}
+
+// wordCount returns the number of words stored in the subtree rooted at n
+func (n *radixNode) wordCount() int {
+	count := len(n.words)
+	for _, edge := range n.edges {
+		count += edge.node.wordCount()
+	}
+	return count
+}
+
+// prefixes appends every non-empty prefix of the keys below n, given the prefix
+// that leads to n
+func (n *radixNode) prefixes(path string, out []string) []string {
+	for _, edge := range n.edges {
+		for i := 1; i <= len(edge.label); i++ {
+			out = append(out, path+edge.label[:i])
+		}
+		out = edge.node.prefixes(path+edge.label, out)
+	}
+	return out
+}
+
+// nodeCount returns the number of nodes below n, not counting n itself
+func (n *radixNode) nodeCount() int {
+	count := 0
+	for _, edge := range n.edges {
+		count += 1 + edge.node.nodeCount()
+	}
+	return count
+}
+
+// compact reallocates the word and edge slices of the subtree rooted at n at
+// their exact lengths
+func (n *radixNode) compact() {
+	if cap(n.words) > len(n.words) {
+		n.words = append(make([]string, 0, len(n.words)), n.words...)
+	}
+	if cap(n.edges) > len(n.edges) {
+		n.edges = append(make([]radixEdge, 0, len(n.edges)), n.edges...)
+	}
+	for _, edge := range n.edges {
+		edge.node.compact()
+	}
+}
+
+// spareBytes returns the unused capacity, in bytes, of the word and edge slices
+// of the subtree rooted at n
+func (n *radixNode) spareBytes() int {
+	spare := (cap(n.words)-len(n.words))*stringHeaderBytes + (cap(n.edges)-len(n.edges))*radixEdgeBytes
+	for _, edge := range n.edges {
+		spare += edge.node.spareBytes()
+	}
+	return spare
+}
+
+// clone returns a deep copy of the subtree rooted at n
+func (n *radixNode) clone() *radixNode {
+	c := &radixNode{words: append([]string(nil), n.words...)}
+	if len(n.edges) > 0 {
+		c.edges = make([]radixEdge, len(n.edges))
+		for i, edge := range n.edges {
+			c.edges[i] = radixEdge{label: edge.label, node: edge.node.clone()}
+		}
+	}
+	return c
+}
+
+// commonPrefixLen returns the length in bytes of the longest common prefix of a and b
+func commonPrefixLen(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,71 @@
+package main
+
+import "strings"
+
+// Session models a typeahead interaction: each typed character extends the
+// query and the matches are narrowed from the previous result instead of being
+// looked up again whenever that gives the same answer as a fresh Search.
+type Session struct {
+	sst     *StaticSearchTree
+	query   string
+	key     string
+	matches []string
+	version uint64
+	
+	// searches counts the full lookups, as opposed to narrowed results
+	searches int
+}
+
+// NewSession starts a typeahead session with an empty query
+func (sst *StaticSearchTree) NewSession() *Session {
+	return &Session{sst: sst}
+}
+
+// Type appends ch to the query and returns the updated matches
+func (s *Session) Type(ch rune) []string {
+	s.sst.mu.RLock()
+	defer s.sst.mu.RUnlock()
+	s.query += string(ch)
+	key := s.sst.normalize(s.query)
+	if s.canNarrow(key) {
+		narrowed := []string{}
+		for _, word := range s.matches {
+			if matchesPrefix(s.sst.normalize(word), key, s.sst.opts) {
+				narrowed = append(narrowed, word)
+			}
+		}
+		s.matches = narrowed
+	} else {
+		s.matches = s.sst.search(s.query)
+		s.version = s.sst.version
+		s.searches++
+	}
+	s.key = key
+	result := make([]string, len(s.matches))
+	copy(result, s.matches)
+	return result
+}
+
+// canNarrow reports whether filtering the previous matches by key gives the same
+// result as a fresh search. That requires a previous result for the same tree
+// version that MinQueryLength did not suppress, a key extending the previous one,
+// prefix matching, since a longer substring or whole word need not share the
+// previous matches, and no variant collapsing, since a collapsed representative
+// may stop matching while one of its variants still does.
+func (s *Session) canNarrow(key string) bool {
+	return s.key != "" && !s.sst.opts.shortQuery(s.key) && s.version == s.sst.version &&
+		strings.HasPrefix(key, s.key) && s.sst.opts.MatchMode == MatchPrefix &&
+		!s.sst.opts.WhitespaceInsensitiveDedup
+}
+
+// Query returns the text typed so far
+func (s *Session) Query() string {
+	return s.query
+}
+
+// Reset clears the query so the session can start over
+func (s *Session) Reset() {
+	s.query = ""
+	s.key = ""
+	s.matches = nil
+}